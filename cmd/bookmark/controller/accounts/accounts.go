@@ -2,6 +2,7 @@ package accounts
 
 import (
 	"bookmark/cmd/bookmark/database"
+	"bookmark/cmd/bookmark/internal"
 	"bookmark/cmd/bookmark/model"
 	"context"
 	"github.com/cute-angelia/go-utils/components/igorm"
@@ -82,6 +83,7 @@ func (that Accounts) add(w http.ResponseWriter, r *http.Request) {
 		account.Password = string(hashedPassword)
 		account.Owner = u.Owner
 		orm.Create(&account)
+		internal.EmitWebhook(model.WebhookEventAccountCreated, account)
 	}
 
 	apiV2.Success(w, r, account, "添加成功")
@@ -121,6 +123,7 @@ func (that Accounts) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	} else {
 		orm.Table(account.TableName()).Where("username = ?", u.Username).Delete(&account)
+		internal.EmitWebhook(model.WebhookEventAccountDeleted, account)
 	}
 
 	apiV2.Success(w, r, account, "删除成功")
@@ -176,6 +179,7 @@ func (that Accounts) changePwd(w http.ResponseWriter, r *http.Request) {
 		account.Password = string(hashedPassword)
 		account.Owner = u.Owner
 		orm.Save(&account)
+		internal.EmitWebhook(model.WebhookEventAccountUpdated, account)
 		apiV2.Success(w, r, account, "修改成功")
 		return
 	}
@@ -0,0 +1,57 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/pkg/storage"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+// 存档页面只允许使用内联的样式、图片和字体, 禁止脚本、表单和外部请求
+const archiveCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline' data:; font-src data:; media-src data:; " +
+	"base-uri 'none'; form-action 'none'; frame-ancestors 'self'; sandbox allow-popups allow-popups-to-escape-sandbox"
+
+// archive 查看书签的单文件网页存档, snapshot 参数指定快照, 默认为最新的存档
+func (that Bookmarks) archive(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	uid := int(apiV2.GetLoginUid(r))
+
+	bookmarkInternal := internal.NewBookmarksInternal()
+	bookmark := bookmarkInternal.InfoById(id)
+	if !internal.CanViewBookmark(bookmark, uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	if snapshotId, _ := strconv.Atoi(apiV2.QueryString(r, "snapshot")); snapshotId > 0 {
+		snapshot := internal.NewSnapshotsInternal().Info(snapshotId)
+		if snapshot.BookmarkId != bookmark.ID {
+			apiV2.Error(w, r, errors.New("快照不存在"))
+			return
+		}
+		bookmark.Archive = snapshot.Archive
+	}
+
+	if len(bookmark.Archive) == 0 {
+		apiV2.Error(w, r, errors.New("存档不存在"))
+		return
+	}
+
+	body, info, err := storage.Default().Get(r.Context(), internal.ArchiveKey(bookmark.Archive))
+	if err != nil {
+		apiV2.Error(w, r, errors.New("存档不存在"))
+		return
+	}
+
+	bookmarkInternal.Visit(bookmark, uid)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", archiveCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	serveObject(w, r, body, info)
+}
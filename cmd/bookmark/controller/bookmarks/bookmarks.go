@@ -3,31 +3,31 @@ package bookmarks
 import (
 	"bookmark/cmd/bookmark/database"
 	"bookmark/cmd/bookmark/internal"
-	"bookmark/cmd/bookmark/internal/consts"
 	"bookmark/cmd/bookmark/internal/errorcode"
+	model2 "bookmark/cmd/bookmark/model"
 	"bookmark/pkg/utils"
-	"bytes"
-	"crypto/md5"
 	"encoding/base64"
-	"fmt"
 	"github.com/cute-angelia/go-utils/syntax/itime"
 	"github.com/cute-angelia/go-utils/utils/http/api"
 	"github.com/cute-angelia/go-utils/utils/http/apiV2"
 	"github.com/cute-angelia/go-utils/utils/http/validation"
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
-	"image"
-	"image/jpeg"
-	"io"
 	"log"
 	"math"
+	"math/rand"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// 书签列表每页数量
+const (
+	defaultPageSize = 30
+	maxPageSize     = 100
+)
+
 type Bookmarks struct {
 }
 
@@ -36,11 +36,34 @@ func (that Bookmarks) Routes() chi.Router {
 	r.Post("/", that.lists)
 
 	r.Post("/add", that.add)
+	r.Post("/suggest", that.suggest)
+	r.Post("/import", that.importBookmarks)
+
+	r.Post("/state", that.state)
+	r.Post("/visit", that.visit)
 
 	r.Post("/delete", that.delete)
 	r.Post("/deleteUrl", that.deleteByUrl)
 
+	r.Post("/duplicates", that.duplicates)
+	r.Post("/merge", that.merge)
+
+	r.Post("/snapshots", that.snapshots)
+	r.Post("/snapshots/diff", that.snapshotDiff)
+
+	r.Post("/notes", that.notes)
+	r.Post("/notes/save", that.saveNote)
+	r.Post("/notes/delete", that.deleteNote)
+	r.Post("/highlights/save", that.saveHighlight)
+	r.Post("/highlights/delete", that.deleteHighlight)
+
+	r.Post("/storage/check", that.storageCheck)
+
 	r.Get("/showShot", that.showShot)
+	r.Get("/export", that.export)
+	r.Get("/{id}/archive", that.archive)
+	r.Get("/{id}/image", that.image)
+	r.Get("/{id}", that.info)
 	return r
 }
 
@@ -50,14 +73,54 @@ func (that Bookmarks) lists(w http.ResponseWriter, r *http.Request) {
 	body := apiV2.NewBody(r)
 	u := struct {
 		Keyword         string
+		Query           string
 		StrPage         int32
 		StrTags         string
 		StrExcludedTags string
+		TagMode         string
+		Status          string
+		Favorite        bool
+		Sort            string
+		Search          int32
+		Domain          string
+		AddedFrom       string
+		AddedTo         string
+		ModifiedFrom    string
+		ModifiedTo      string
+		Screenshot      string
+		Public          string
+		Owner           int32
+		Facets          bool
+		Cursor          string
+		Limit           int32
+		NoTotal         bool
+		Seed            int32
+		Uid             int
 	}{
 		Keyword:         body.PostString("keyword"),
+		Query:           body.PostString("q"),
 		StrPage:         body.PostInt32("page"),
 		StrTags:         body.PostString("tags"),
 		StrExcludedTags: body.PostString("exclude"),
+		TagMode:         body.PostString("tagMode"),
+		Status:          body.PostString("status"),
+		Favorite:        body.PostBool("favorite"),
+		Sort:            body.PostString("sort"),
+		Search:          body.PostInt32("search"),
+		Domain:          body.PostString("domain"),
+		AddedFrom:       body.PostString("addedFrom"),
+		AddedTo:         body.PostString("addedTo"),
+		ModifiedFrom:    body.PostString("modifiedFrom"),
+		ModifiedTo:      body.PostString("modifiedTo"),
+		Screenshot:      body.PostString("screenshot"),
+		Public:          body.PostString("public"),
+		Owner:           body.PostInt32("uid"),
+		Facets:          body.PostBool("facets"),
+		Cursor:          body.PostString("cursor"),
+		Limit:           body.PostInt32("limit"),
+		NoTotal:         body.PostBool("noTotal"),
+		Seed:            body.PostInt32("seed"),
+		Uid:             int(apiV2.GetLoginUid(r)),
 	}
 	if err := valid.Submit(u); err != nil {
 		api.Error(w, r, nil, err.Error(), -1)
@@ -74,34 +137,201 @@ func (that Bookmarks) lists(w http.ResponseWriter, r *http.Request) {
 		excludedTags = []string{}
 	}
 
+	// tagMode 为 any 时满足任意一个标签即可, 默认 all 需要全部满足
+	if u.TagMode != "" && u.TagMode != "all" && u.TagMode != "any" {
+		apiV2.Error(w, r, errors.New("tagMode 只能为 all 或 any"))
+		return
+	}
+
 	page := u.StrPage
 	if page < 1 {
 		page = 1
 	}
 
+	limit := int(u.Limit)
+	if limit <= 0 {
+		limit = defaultPageSize
+	} else if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if len(u.Status) > 0 && !internal.ValidStatus(u.Status) {
+		apiV2.Error(w, r, errors.New("阅读状态错误"))
+		return
+	}
+
+	// 搜索语句, 例如: go "error handling" tag:lang -site:example.com (is:unread OR is:favorite) after:2024-01
+	query, err := database.ParseQuery(u.Query)
+	if err != nil {
+		apiV2.Error(w, r, apiV2.NewApiError(int(errorcode.ErrorBookmarkQuerySyntax), errorcode.ErrorBookmarkQuerySyntax.String()+" > "+err.Error()))
+		return
+	}
+
+	// 使用保存的搜索, 和 q 同时满足
+	if u.Search > 0 {
+		search := internal.NewSavedSearchesInternal().Info(int(u.Search))
+		if !internal.CanViewSavedSearch(search, u.Uid) {
+			apiV2.Error(w, r, errors.New("搜索不存在"))
+			return
+		}
+		opts, err := internal.SavedSearchOptions(search, u.Query)
+		if err != nil {
+			apiV2.Error(w, r, apiV2.NewApiError(int(errorcode.ErrorBookmarkQuerySyntax), errorcode.ErrorBookmarkQuerySyntax.String()+" > "+err.Error()))
+			return
+		}
+		query = opts.Query
+		if len(u.Sort) == 0 {
+			u.Sort = search.Sort
+		}
+	}
+
+	orderMethod, reverse, ok := internal.SortOrder(u.Sort)
+	if !ok {
+		apiV2.Error(w, r, errors.New("排序错误"))
+		return
+	}
+	// 随机排序使用相同的种子翻页, 按游标翻页时种子在游标中
+	seed := int64(u.Seed)
+	if orderMethod == database.ByRandom && seed <= 0 {
+		seed = rand.Int63n(math.MaxInt32) + 1
+	}
+
+	// 时间范围和截图、公开筛选
+	var ranges [4]time.Time
+	for i, value := range []string{u.AddedFrom, u.AddedTo, u.ModifiedFrom, u.ModifiedTo} {
+		if ranges[i], err = parseTime(value, i%2 == 1); err != nil {
+			apiV2.Error(w, r, err)
+			return
+		}
+	}
+	screenshot, err := parseTriState(u.Screenshot)
+	if err != nil {
+		apiV2.Error(w, r, errors.Wrap(err, "screenshot"))
+		return
+	}
+	public, err := parseTriState(u.Public)
+	if err != nil {
+		apiV2.Error(w, r, errors.Wrap(err, "public"))
+		return
+	}
+
 	// Prepare filter for database
 	searchOptions := database.GetBookmarksOptions{
 		Tags:         tags,
+		AnyTags:      u.TagMode == "any",
 		ExcludedTags: excludedTags,
 		Keyword:      u.Keyword,
-		Limit:        30,
-		OrderMethod:  database.ByLastAdded,
+		Query:        query,
+		Status:       u.Status,
+		Favorite:     u.Favorite,
+		Limit:        limit,
+		OrderMethod:  orderMethod,
+		Reverse:      reverse,
+		Seed:         seed,
+		Domain:       u.Domain,
+		AddedFrom:    ranges[0],
+		AddedTo:      ranges[1],
+		ModifiedFrom: ranges[2],
+		ModifiedTo:   ranges[3],
+		Screenshot:   screenshot,
+		Public:       public,
+		Uid:          int(u.Owner),
 	}
 
 	bookmarkInternal := internal.NewBookmarksInternal()
-	bookmarks, count := bookmarkInternal.GetBookmarkList(searchOptions, int(page), 30)
+	var (
+		bookmarks []model2.BookmarkModel
+		next      *database.Cursor
+		count     int64
+	)
+	if len(u.Cursor) > 0 || page == 1 {
+		// 按游标分页, 不使用 OFFSET, 翻页时新添加的书签不会导致重复或遗漏
+		if len(u.Cursor) > 0 {
+			if searchOptions.After, err = database.ParseCursor(u.Cursor); err != nil {
+				apiV2.Error(w, r, err)
+				return
+			}
+			if orderMethod == database.ByRandom {
+				seed = searchOptions.After.Seed
+			}
+		}
+		if bookmarks, next, count, err = bookmarkInternal.GetBookmarkPage(searchOptions, limit, !u.NoTotal); err != nil {
+			apiV2.Error(w, r, err)
+			return
+		}
+	} else {
+		bookmarks, count = bookmarkInternal.GetBookmarkList(searchOptions, int(page), limit)
+		if int(page)*limit < int(count) && len(bookmarks) > 0 {
+			cursor := database.NewCursor(searchOptions, bookmarks[len(bookmarks)-1], int(page)*limit)
+			next = &cursor
+		}
+	}
+	bookmarks = bookmarkInternal.FillImageSrc(bookmarks, u.Uid)
 
-	maxPage := int(math.Ceil(float64(count) / 30))
 	// Return JSON response
 	resp := map[string]interface{}{
 		"page":      page,
-		"maxPage":   maxPage,
 		"bookmarks": bookmarks,
+		"next":      "",
+	}
+	if next != nil {
+		resp["next"] = next.String()
+	}
+	if orderMethod == database.ByRandom {
+		resp["seed"] = seed
+	}
+	// noTotal 时不统计总数, 按游标翻页时可以省去每次的 COUNT
+	if !u.NoTotal || len(u.Cursor) == 0 && page > 1 {
+		resp["total"] = count
+		resp["maxPage"] = int(math.Ceil(float64(count) / float64(limit)))
+		resp["counts"] = bookmarkInternal.CountByState(searchOptions)
+	}
+	// 搜索结果的标签、域名、月份和阅读状态统计
+	if u.Facets {
+		resp["facets"] = bookmarkInternal.Facets(searchOptions, internal.FacetLimit)
 	}
 	api.Success(w, r, resp, "获取书签列表")
 	return
 }
 
+// parseTime 解析筛选时间, 支持 2006-01-02、2006-01-02 15:04:05 和秒级时间戳, 为空时返回零值.
+// end 为范围结束时间, 只有日期时包括当天
+func parseTime(s string, end bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if t, err := time.ParseInLocation(model2.TimeLayout, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, errors.New("时间格式错误: " + s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseTriState 解析 1/0 筛选, 为空时不限
+func parseTriState(s string) (*bool, error) {
+	switch strings.TrimSpace(s) {
+	case "":
+		return nil, nil
+	case "1", "true":
+		value := true
+		return &value, nil
+	case "0", "false":
+		value := false
+		return &value, nil
+	}
+	return nil, errors.New("只能为 1 或 0")
+}
+
 func (that Bookmarks) add(w http.ResponseWriter, r *http.Request) {
 	// 校验参数
 	valid := validation.Validation{}
@@ -143,7 +373,6 @@ func (that Bookmarks) add(w http.ResponseWriter, r *http.Request) {
 	bookmarkInternal := internal.NewBookmarksInternal()
 	bookmark := bookmarkInternal.Info(u.Url)
 
-	bookmark.Tags = strings.Join(tags, ",")
 	bookmark.URL = u.Url
 	bookmark.Title = u.Title
 	bookmark.Excerpt = u.Excerpt
@@ -157,22 +386,52 @@ func (that Bookmarks) add(w http.ResponseWriter, r *http.Request) {
 			log.Println("err", err)
 		}
 	} else {
-		// 要不要更新截图？ 删除旧图 todo
+		// 有新截图时更新, 旧截图保留在之前的快照中
+		if len(u.Imgbase64) > 0 {
+			if imageURL, err := that.saveImageFromBase64(u.Imgbase64); err != nil {
+				log.Println("err", err)
+			} else {
+				bookmark.ImageURL = imageURL
+			}
+		}
+
 		// 来源是插件，保留之前的tag
 		if u.From == "ext" {
 			oldTas := internal.NewTagInternal().GetTags(bookmark.Tags)
 			for _, ta := range oldTas {
-				tags = append(tags, ta.Name)
+				if ta.ID > 0 {
+					tags = append(tags, ta.Name)
+				}
 			}
 		}
 	}
 
 	book := bookmarkInternal.CreateOrEdit(bookmark, tags)
+	book = bookmarkInternal.FillImageSrc([]model2.BookmarkModel{book}, int(u.LoginUid))[0]
 
 	apiV2.Success(w, r, book, "添加书签成功")
 	return
 }
 
+// info 书签详情, related 为按共同标签、域名和内容相似度推荐的相关书签
+func (that Bookmarks) info(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	uid := int(apiV2.GetLoginUid(r))
+
+	bookmarkInternal := internal.NewBookmarksInternal()
+	bookmark := bookmarkInternal.InfoById(id)
+	if !internal.CanViewBookmark(bookmark, uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+	bookmark = bookmarkInternal.FillImageSrc(bookmarkInternal.FillTagsDetail([]model2.BookmarkModel{bookmark}), uid)[0]
+
+	api.Success(w, r, map[string]interface{}{
+		"bookmark": bookmark,
+		"related":  bookmarkInternal.Related(bookmark, uid, internal.RelatedLimit),
+	}, "获取书签详情")
+}
+
 func (that Bookmarks) delete(w http.ResponseWriter, r *http.Request) {
 	// 校验参数
 	valid := validation.Validation{}
@@ -233,45 +492,6 @@ func (that Bookmarks) deleteByUrl(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func (that Bookmarks) showShot(w http.ResponseWriter, r *http.Request) {
-	image_url := apiV2.QueryString(r, "image_url")
-
-	// 拼接头像文件路径
-	filePath := filepath.Join(consts.UploadDir, image_url)
-
-	// 打开文件
-	file, err := os.Open(filePath)
-	if err != nil {
-		http.Error(w, "Avatar not found", http.StatusNotFound)
-		return
-	}
-	defer file.Close()
-
-	// 设置HTTP头
-	ext := filepath.Ext(filePath)
-	switch ext {
-	case ".jpg":
-		w.Header().Set("Content-Type", "image/jpeg")
-	case ".png":
-		w.Header().Set("Content-Type", "image/png")
-	default:
-		w.Header().Set("Content-Type", "image/jpeg")
-	}
-
-	w.Header().Set("Content-Disposition", "inline")
-	w.Header().Del("Content-Length")
-
-	// 生成缓存Key
-	cacheKey := fmt.Sprintf("%x", md5.Sum([]byte(image_url)))
-
-	// 设置缓存相关头
-	w.Header().Set("ETag", cacheKey)
-	w.Header().Set("Cache-Control", "public, max-age=864000")
-
-	// 将文件复制到response
-	io.Copy(w, file)
-}
-
 func (that Bookmarks) saveImageFromBase64(base64Str string) (string, error) {
 	if len(base64Str) == 0 {
 		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64Empty), errorcode.ErrorBookmarkBase64Empty.String())
@@ -286,28 +506,6 @@ func (that Bookmarks) saveImageFromBase64(base64Str string) (string, error) {
 		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64Error), errorcode.ErrorBookmarkBase64Error.String()+" > "+err.Error())
 	}
 
-	// 5. 构造文件名及文件路径
-	fileName := fmt.Sprintf("%d.jpg", time.Now().UnixNano())
-	filePath := filepath.Join(consts.UploadDir, fileName)
-
-	dir := filepath.Dir(filePath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		os.MkdirAll(dir, 0755)
-	}
-
-	// 6. 将图片数据写入文件
-	out, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0777)
-	if err != nil {
-		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64WriteFile), errorcode.ErrorBookmarkBase64WriteFile.String()+" > "+err.Error())
-	}
-	defer out.Close()
-
-	// 7. 编码图片信息
-	img, _, err := image.Decode(bytes.NewReader(imgData))
-	if err != nil {
-		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64Decode), errorcode.ErrorBookmarkBase64Decode.String()+" > "+err.Error())
-	}
-	jpeg.Encode(out, img, &jpeg.Options{Quality: 95})
-
-	return fileName, nil
+	// 5. 保存图片
+	return internal.SaveImage(imgData)
 }
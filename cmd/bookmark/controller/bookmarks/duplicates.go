@@ -0,0 +1,116 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	model2 "bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// duplicates 重复书签报告
+func (that Bookmarks) duplicates(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	u := struct {
+		Uid int32 `valid:"Required;"`
+	}{
+		Uid: apiV2.GetLoginUid(r),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	groups := internal.NewDuplicatesInternal().Report(int(u.Uid))
+	api.Success(w, r, groups, "获取重复书签")
+	return
+}
+
+// merge 合并重复书签, 保留 keep 书签 (默认最早添加的), 删除其余书签
+func (that Bookmarks) merge(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Uid    int32  `valid:"Required;"`
+		StrIds string `valid:"Required;"`
+		Keep   int32
+	}{
+		Uid:    apiV2.GetLoginUid(r),
+		StrIds: body.PostString("ids"),
+		Keep:   body.PostInt32("keep"),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	bookmarkInternal := internal.NewBookmarksInternal()
+
+	var bookmarks []model2.BookmarkModel
+	seen := map[int]bool{}
+	for _, idStr := range strings.Split(u.StrIds, ",") {
+		id, _ := strconv.Atoi(strings.TrimSpace(idStr))
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		bookmark := bookmarkInternal.InfoById(id)
+		if bookmark.ID <= 0 || bookmark.Uid != int(u.Uid) {
+			apiV2.Error(w, r, errors.New("书签不存在"))
+			return
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	if len(bookmarks) < 2 {
+		apiV2.Error(w, r, errors.New("至少需要两个书签才能合并"))
+		return
+	}
+
+	// 没有指定时保留最早添加的书签, 添加时间相同或未知时保留 id 最小的
+	keepIndex := -1
+	for i, bookmark := range bookmarks {
+		switch {
+		case u.Keep > 0:
+			if bookmark.ID == int(u.Keep) {
+				keepIndex = i
+			}
+		case keepIndex < 0 || earlierAdded(bookmark, bookmarks[keepIndex]):
+			keepIndex = i
+		}
+	}
+	if keepIndex < 0 {
+		apiV2.Error(w, r, errors.New("保留的书签不在合并列表中"))
+		return
+	}
+
+	keep := bookmarks[keepIndex]
+	var others []model2.BookmarkModel
+	for i, bookmark := range bookmarks {
+		if i != keepIndex {
+			others = append(others, bookmark)
+		}
+	}
+
+	book := internal.NewDuplicatesInternal().Merge(keep, others)
+	apiV2.Success(w, r, book, "合并成功")
+	return
+}
+
+// earlierAdded a 是否比 b 添加得早, 没有添加时间的排在后面, 相同时比较 id
+func earlierAdded(a, b model2.BookmarkModel) bool {
+	if a.CreatedAt != b.CreatedAt {
+		if a.CreatedAt <= 0 || b.CreatedAt <= 0 {
+			return a.CreatedAt > 0
+		}
+		return a.CreatedAt < b.CreatedAt
+	}
+	return a.ID < b.ID
+}
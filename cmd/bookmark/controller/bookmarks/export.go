@@ -0,0 +1,56 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/pkg/warc"
+	"fmt"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/pkg/errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// export 导出书签, ids 为逗号分隔的书签 id, 为空时导出全部书签
+// format=warc 导出网页存档 (每条记录单独 gzip 压缩的 WARC 1.1), 笔记和高亮写入 metadata 记录
+// format=json 导出书签、标签、笔记和高亮
+// format=html 导出为浏览器书签文件, 标签路径作为文件夹
+func (that Bookmarks) export(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if uid <= 0 {
+		apiV2.Error(w, r, errors.New("请先登录"))
+		return
+	}
+
+	bookmarks := internal.NewBookmarksInternal().GetUserBookmarks(uid, parseIds(apiV2.QueryString(r, "ids")))
+	if len(bookmarks) == 0 {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	switch apiV2.QueryString(r, "format") {
+	case "warc":
+		filename := fmt.Sprintf("bookmarks-%s.warc.gz", time.Now().Format("20060102150405"))
+		w.Header().Set("Content-Type", warc.ContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if err := internal.ExportWarc(w, filename, bookmarks); err != nil {
+			log.Println("export warc", err)
+		}
+	case "json":
+		filename := fmt.Sprintf("bookmarks-%s.json", time.Now().Format("20060102150405"))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if err := internal.ExportJSON(w, bookmarks); err != nil {
+			log.Println("export json", err)
+		}
+	case "html":
+		filename := fmt.Sprintf("bookmarks-%s.html", time.Now().Format("20060102150405"))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if err := internal.ExportNetscape(w, bookmarks); err != nil {
+			log.Println("export html", err)
+		}
+	default:
+		apiV2.Error(w, r, errors.New("不支持的导出格式"))
+	}
+}
@@ -0,0 +1,125 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/pkg/storage"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/go-chi/chi"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// image 查看书签截图, snapshot 指定快照. <img> 标签无法携带 Authorization 头, 该地址不校验登录,
+// 只允许公开书签或列表返回的签名地址 (exp、sig)
+func (that Bookmarks) image(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	snapshotId, _ := strconv.Atoi(apiV2.QueryString(r, "snapshot"))
+	exp, _ := strconv.ParseInt(apiV2.QueryString(r, "exp"), 10, 64)
+	sig := apiV2.QueryString(r, "sig")
+
+	bookmark := internal.NewBookmarksInternal().InfoById(id)
+	if bookmark.ID <= 0 {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	// 不区分无权限和不存在, 避免枚举书签
+	if bookmark.Public != 1 && !internal.VerifyImageSign(bookmark.ID, snapshotId, exp, sig) {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	imageURL := bookmark.ImageURL
+	if snapshotId > 0 {
+		snapshot := internal.NewSnapshotsInternal().Info(snapshotId)
+		if snapshot.BookmarkId != bookmark.ID {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		imageURL = snapshot.ImageURL
+	}
+
+	that.serveImage(w, r, imageURL)
+}
+
+// showShot 按文件名查看截图, 需要登录, 并且截图属于可以查看的书签
+func (that Bookmarks) showShot(w http.ResponseWriter, r *http.Request) {
+	imageURL := apiV2.QueryString(r, "image_url")
+	if !validImageName(imageURL) {
+		http.Error(w, "Bad image name", http.StatusBadRequest)
+		return
+	}
+
+	if !internal.NewBookmarksInternal().CanViewImage(imageURL, int(apiV2.GetLoginUid(r))) {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	that.serveImage(w, r, imageURL)
+}
+
+// serveImage 输出截图, w 指定缩略图宽度, 浏览器支持时返回 webp
+func (that Bookmarks) serveImage(w http.ResponseWriter, r *http.Request, imageURL string) {
+	if !validImageName(imageURL) {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	width, _ := strconv.Atoi(apiV2.QueryString(r, "w"))
+	acceptWebp := strings.Contains(r.Header.Get("Accept"), "image/webp")
+
+	image, err := internal.ImageVariant(imageURL, width, acceptWebp)
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	body, info, err := storage.Default().Get(r.Context(), image.Key)
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	// 设置HTTP头, 截图需要权限, 只允许浏览器缓存
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("ETag", image.ETag)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	serveObject(w, r, body, info)
+}
+
+// serveObject 输出存储中的文件, 不读入内存. 本地文件由 http.ServeContent 处理 Range 和条件请求,
+// 其他存储只处理 If-None-Match
+func serveObject(w http.ResponseWriter, r *http.Request, body io.ReadCloser, info storage.ObjectInfo) {
+	defer body.Close()
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", info.ModTime, seeker)
+		return
+	}
+
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if etag := w.Header().Get("ETag"); len(etag) > 0 && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, body)
+}
+
+// validImageName 截图文件名不能包含路径
+func validImageName(name string) bool {
+	return len(name) > 0 && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
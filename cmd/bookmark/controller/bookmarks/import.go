@@ -0,0 +1,46 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/pkg/netscape"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+// 导入文件大小限制
+const maxImportSize = 32 << 20
+
+// importBookmarks 导入浏览器导出的书签文件 (multipart 字段 file), 文件夹路径导入为标签路径.
+// public=1 时没有标记 PRIVATE 的书签设为公开
+func (that Bookmarks) importBookmarks(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if uid <= 0 {
+		apiV2.Error(w, r, errors.New("请先登录"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		apiV2.Error(w, r, errors.New("请上传书签文件"))
+		return
+	}
+	defer file.Close()
+
+	items, err := netscape.Parse(file)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	if len(items) == 0 {
+		apiV2.Error(w, r, errors.New("没有找到书签"))
+		return
+	}
+
+	public := r.FormValue("public")
+	result := internal.ImportBookmarks(uid, items, public == "1" || strings.EqualFold(public, "true"))
+	api.Success(w, r, result, "导入书签")
+}
@@ -0,0 +1,36 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// storageCheck 检查存储中缺失和没有被引用的文件, 仅管理员可用.
+// delete 删除孤儿文件, refetch 为截图缺失的书签重新下载预览图
+func (that Bookmarks) storageCheck(w http.ResponseWriter, r *http.Request) {
+	body := apiV2.NewBody(r)
+	u := struct {
+		Uid     int
+		Delete  bool
+		Refetch bool
+	}{
+		Uid:     int(apiV2.GetLoginUid(r)),
+		Delete:  body.PostBool("delete"),
+		Refetch: body.PostBool("refetch"),
+	}
+
+	if !internal.IsOwnerAccount(u.Uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能检查存储"))
+		return
+	}
+
+	report, err := internal.NewMaintenanceInternal().CheckStorage(u.Delete, u.Refetch)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, report, "检查存储")
+}
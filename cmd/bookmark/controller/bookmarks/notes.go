@@ -0,0 +1,219 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	model2 "bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// notes 书签的笔记和高亮
+func (that Bookmarks) notes(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	bookmark := internal.NewBookmarksInternal().InfoById(int(u.Id))
+	if !internal.CanViewBookmark(bookmark, u.Uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	api.Success(w, r, internal.NewNotesInternal().Export(bookmark.ID), "获取笔记")
+}
+
+// saveNote 新建或修改 Markdown 笔记, id 为空时新建
+func (that Bookmarks) saveNote(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		BookmarkId int32 `valid:"Required;"`
+		Id         int32
+		Content    string
+		Uid        int
+	}{
+		BookmarkId: body.PostInt32("bookmarkId"),
+		Id:         body.PostInt32("id"),
+		Content:    body.PostString("content"),
+		Uid:        int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if len(strings.TrimSpace(u.Content)) == 0 {
+		apiV2.Error(w, r, errors.New("笔记内容不能为空"))
+		return
+	}
+
+	bookmark := internal.NewBookmarksInternal().InfoById(int(u.BookmarkId))
+	if !internal.CanEditBookmark(bookmark, u.Uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	notesInternal := internal.NewNotesInternal()
+	note := model2.BookmarkNoteModel{
+		BookmarkId: bookmark.ID,
+		Uid:        u.Uid,
+		Content:    u.Content,
+	}
+	if u.Id > 0 {
+		if old := notesInternal.NoteInfo(int(u.Id)); old.BookmarkId != bookmark.ID {
+			apiV2.Error(w, r, errors.New("笔记不存在"))
+			return
+		}
+		note.ID = int(u.Id)
+	}
+
+	api.Success(w, r, notesInternal.SaveNote(note), "保存笔记")
+}
+
+// deleteNote 删除笔记
+func (that Bookmarks) deleteNote(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	notesInternal := internal.NewNotesInternal()
+	note := notesInternal.NoteInfo(int(u.Id))
+	if !internal.CanEditBookmark(internal.NewBookmarksInternal().InfoById(note.BookmarkId), u.Uid) {
+		apiV2.Error(w, r, errors.New("笔记不存在"))
+		return
+	}
+
+	notesInternal.DeleteNote(note.ID)
+	apiV2.Success(w, r, nil, "删除成功")
+}
+
+// saveHighlight 保存存档正文中的高亮. 新建时 exact 为选中的文本, prefix、suffix 为前后文, start 为字符位置,
+// 服务端在快照正文中重新定位; 传 id 时只修改颜色和批注
+func (that Bookmarks) saveHighlight(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		BookmarkId int32 `valid:"Required;"`
+		Id         int32
+		SnapshotId int32
+		Exact      string
+		Prefix     string
+		Suffix     string
+		Start      string
+		Color      string
+		Note       string
+		Uid        int
+	}{
+		BookmarkId: body.PostInt32("bookmarkId"),
+		Id:         body.PostInt32("id"),
+		SnapshotId: body.PostInt32("snapshotId"),
+		Exact:      body.PostString("exact"),
+		Prefix:     body.PostString("prefix"),
+		Suffix:     body.PostString("suffix"),
+		Start:      body.PostString("start"),
+		Color:      body.PostString("color"),
+		Note:       body.PostString("note"),
+		Uid:        int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	bookmark := internal.NewBookmarksInternal().InfoById(int(u.BookmarkId))
+	if !internal.CanEditBookmark(bookmark, u.Uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	notesInternal := internal.NewNotesInternal()
+	if u.Id > 0 {
+		if old := notesInternal.HighlightInfo(int(u.Id)); old.BookmarkId != bookmark.ID {
+			apiV2.Error(w, r, errors.New("高亮不存在"))
+			return
+		}
+		api.Success(w, r, notesInternal.UpdateHighlight(int(u.Id), u.Color, u.Note), "保存高亮")
+		return
+	}
+
+	// 没有位置时按前后文定位
+	start, err := strconv.Atoi(u.Start)
+	if err != nil {
+		start = -1
+	}
+
+	highlight, err := notesInternal.CreateHighlight(model2.BookmarkHighlightModel{
+		BookmarkId: bookmark.ID,
+		SnapshotId: int(u.SnapshotId),
+		Uid:        u.Uid,
+		Exact:      u.Exact,
+		Prefix:     u.Prefix,
+		Suffix:     u.Suffix,
+		Start:      start,
+		End:        start + len([]rune(u.Exact)),
+		Color:      u.Color,
+		Note:       u.Note,
+	})
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, highlight, "保存高亮")
+}
+
+// deleteHighlight 删除高亮
+func (that Bookmarks) deleteHighlight(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	notesInternal := internal.NewNotesInternal()
+	highlight := notesInternal.HighlightInfo(int(u.Id))
+	if !internal.CanEditBookmark(internal.NewBookmarksInternal().InfoById(highlight.BookmarkId), u.Uid) {
+		apiV2.Error(w, r, errors.New("高亮不存在"))
+		return
+	}
+
+	notesInternal.DeleteHighlight(highlight.ID)
+	apiV2.Success(w, r, nil, "删除成功")
+}
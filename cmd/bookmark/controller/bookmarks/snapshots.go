@@ -0,0 +1,80 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// snapshots 书签的快照列表
+func (that Bookmarks) snapshots(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	bookmark := internal.NewBookmarksInternal().InfoById(int(u.Id))
+	if !internal.CanViewBookmark(bookmark, u.Uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	list := internal.NewSnapshotsInternal().List(bookmark.ID)
+	for i, snapshot := range list {
+		if len(snapshot.ImageURL) > 0 {
+			list[i].ImageSrc = internal.SignedImageURL(bookmark.ID, snapshot.ID, snapshot.ImageURL)
+		}
+	}
+	api.Success(w, r, list, "获取快照列表")
+	return
+}
+
+// snapshotDiff 比较同一书签两个快照的正文
+func (that Bookmarks) snapshotDiff(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		From int32 `valid:"Required;"`
+		To   int32 `valid:"Required;"`
+		Uid  int
+	}{
+		From: body.PostInt32("from"),
+		To:   body.PostInt32("to"),
+		Uid:  int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	snapshotInternal := internal.NewSnapshotsInternal()
+	from := snapshotInternal.Info(int(u.From))
+	to := snapshotInternal.Info(int(u.To))
+	if from.ID <= 0 || to.ID <= 0 || from.BookmarkId != to.BookmarkId {
+		apiV2.Error(w, r, errors.New("快照不存在"))
+		return
+	}
+
+	bookmark := internal.NewBookmarksInternal().InfoById(from.BookmarkId)
+	if !internal.CanViewBookmark(bookmark, u.Uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	api.Success(w, r, snapshotInternal.Diff(from, to), "比较快照")
+	return
+}
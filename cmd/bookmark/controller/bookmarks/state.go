@@ -0,0 +1,89 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// state 批量修改阅读状态和收藏
+// ids 逗号分隔的书签 id, status 为 unread、read、archived, favorite 为 1 收藏、0 取消收藏, 为空时不修改
+func (that Bookmarks) state(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Ids      string `valid:"Required;"`
+		Status   string
+		Favorite string
+		Uid      int
+	}{
+		Ids:      body.PostString("ids"),
+		Status:   body.PostString("status"),
+		Favorite: body.PostString("favorite"),
+		Uid:      int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if len(u.Status) > 0 && !internal.ValidStatus(u.Status) {
+		apiV2.Error(w, r, errors.New("阅读状态错误"))
+		return
+	}
+
+	var favorite *bool
+	switch u.Favorite {
+	case "":
+	case "1", "true":
+		favorite = new(bool)
+		*favorite = true
+	case "0", "false":
+		favorite = new(bool)
+	default:
+		apiV2.Error(w, r, errors.New("收藏参数错误"))
+		return
+	}
+
+	if len(u.Status) == 0 && favorite == nil {
+		apiV2.Error(w, r, errors.New("没有需要修改的状态"))
+		return
+	}
+
+	updated := internal.NewBookmarksInternal().SetState(u.Uid, parseIds(u.Ids), u.Status, favorite)
+	api.Success(w, r, map[string]interface{}{
+		"updated": updated,
+	}, "修改阅读状态")
+}
+
+// visit 打开书签时记录打开时间, 用于按打开时间排序
+func (that Bookmarks) visit(w http.ResponseWriter, r *http.Request) {
+	body := apiV2.NewBody(r)
+	uid := int(apiV2.GetLoginUid(r))
+
+	bookmarkInternal := internal.NewBookmarksInternal()
+	bookmark := bookmarkInternal.InfoById(int(body.PostInt32("id")))
+	if !internal.CanViewBookmark(bookmark, uid) {
+		apiV2.Error(w, r, errors.New("书签不存在"))
+		return
+	}
+
+	bookmarkInternal.Visit(bookmark, uid)
+	api.Success(w, r, nil, "记录打开时间")
+}
+
+// parseIds 解析逗号分隔的书签 id
+func parseIds(s string) (ids []int) {
+	for _, idStr := range strings.Split(s, ",") {
+		if id, _ := strconv.Atoi(strings.TrimSpace(idStr)); id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
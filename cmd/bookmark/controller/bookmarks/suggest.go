@@ -0,0 +1,50 @@
+package bookmarks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/pkg/utils"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"net/http"
+)
+
+// maxSuggestLimit 最多推荐的标签数量
+const maxSuggestLimit = 50
+
+// suggest 保存书签前推荐标签, 按同域名书签的标签、标题和摘要相似的书签的标签、最近使用的标签排序
+func (that Bookmarks) suggest(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Url     string `valid:"Required;"`
+		Title   string
+		Excerpt string
+		Limit   int32
+		Uid     int32 `valid:"Required;"`
+	}{
+		Url:     body.PostString("url"),
+		Title:   body.PostString("title"),
+		Excerpt: body.PostString("excerpt"),
+		Limit:   body.PostInt32("limit"),
+		Uid:     apiV2.GetLoginUid(r),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	uri, err := utils.RemoveUTMParams(u.Url)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	limit := int(u.Limit)
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	suggestions := internal.NewBookmarksInternal().SuggestTags(int(u.Uid), uri, u.Title, u.Excerpt, limit)
+	api.Success(w, r, suggestions, "推荐标签")
+}
@@ -0,0 +1,195 @@
+package tags
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	model2 "bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+// previewLimit 预览最多返回的书签数量
+const previewLimit = 100
+
+// rules 自己的自动标签规则, 新建和导入书签时按 id 顺序应用
+func (that Tags) rules(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if uid <= 0 {
+		apiV2.Error(w, r, errors.New("请先登录"))
+		return
+	}
+
+	api.Success(w, r, internal.NewTagRulesInternal().List(uid), "获取自动标签规则")
+}
+
+// saveRule 新建或修改自动标签规则, id 为空时新建
+func (that Tags) saveRule(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if uid <= 0 {
+		apiV2.Error(w, r, errors.New("请先登录"))
+		return
+	}
+
+	body := apiV2.NewBody(r)
+	rule, err := ruleFromBody(body.PostString, uid)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+
+	rulesInternal := internal.NewTagRulesInternal()
+	old := model2.TagRuleModel{Enabled: 1}
+	if id := int(body.PostInt32("id")); id > 0 {
+		if old = rulesInternal.Info(id); old.ID <= 0 || old.Uid != uid {
+			apiV2.Error(w, r, errors.New("规则不存在"))
+			return
+		}
+		rule.ID = id
+	}
+
+	// enabled 为空时新规则启用, 修改时保持不变
+	switch body.PostString("enabled") {
+	case "":
+		rule.Enabled = old.Enabled
+	case "1", "true":
+		rule.Enabled = 1
+	case "0", "false":
+		rule.Enabled = 0
+	default:
+		apiV2.Error(w, r, errors.New("enabled 只能为 1 或 0"))
+		return
+	}
+
+	rule, err = rulesInternal.Save(rule)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, rule, "保存规则")
+}
+
+// deleteRule 删除自动标签规则
+func (that Tags) deleteRule(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	rulesInternal := internal.NewTagRulesInternal()
+	if rule := rulesInternal.Info(int(u.Id)); rule.ID <= 0 || rule.Uid != u.Uid {
+		apiV2.Error(w, r, errors.New("规则不存在"))
+		return
+	}
+
+	rulesInternal.Delete(int(u.Id))
+	apiV2.Success(w, r, nil, "删除成功")
+}
+
+// previewRule 预览规则匹配的已有书签, 传 id 预览保存的规则, 否则按参数预览未保存的规则
+func (that Tags) previewRule(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if uid <= 0 {
+		apiV2.Error(w, r, errors.New("请先登录"))
+		return
+	}
+
+	body := apiV2.NewBody(r)
+	rulesInternal := internal.NewTagRulesInternal()
+	var rule model2.TagRuleModel
+	if id := int(body.PostInt32("id")); id > 0 {
+		if rule = rulesInternal.Info(id); rule.ID <= 0 || rule.Uid != uid {
+			apiV2.Error(w, r, errors.New("规则不存在"))
+			return
+		}
+	} else {
+		var err error
+		if rule, err = ruleFromBody(body.PostString, uid); err != nil {
+			apiV2.Error(w, r, err)
+			return
+		}
+	}
+
+	bookmarks, err := rulesInternal.Preview(rule)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	total := len(bookmarks)
+	if total > previewLimit {
+		bookmarks = bookmarks[:previewLimit]
+	}
+	api.Success(w, r, map[string]interface{}{
+		"total":     total,
+		"bookmarks": internal.NewBookmarksInternal().FillTagsDetail(bookmarks),
+	}, "预览规则")
+}
+
+// applyRule 把保存的规则应用到自己的已有书签
+func (that Tags) applyRule(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	rulesInternal := internal.NewTagRulesInternal()
+	rule := rulesInternal.Info(int(u.Id))
+	if rule.ID <= 0 || rule.Uid != u.Uid {
+		apiV2.Error(w, r, errors.New("规则不存在"))
+		return
+	}
+
+	bookmarks, err := rulesInternal.Apply(rule)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, map[string]interface{}{
+		"updated": len(bookmarks),
+	}, "应用规则")
+}
+
+// ruleFromBody 读取规则参数, public 为空时不修改公开, 1 公开, 0 私有
+func ruleFromBody(post func(key string) string, uid int) (model2.TagRuleModel, error) {
+	rule := model2.TagRuleModel{
+		Uid:       uid,
+		Name:      post("name"),
+		MatchType: strings.TrimSpace(post("matchType")),
+		Pattern:   post("pattern"),
+		Tags:      post("tags"),
+		Status:    strings.TrimSpace(post("status")),
+		Public:    -1,
+	}
+	switch strings.TrimSpace(post("public")) {
+	case "":
+	case "1", "true":
+		rule.Public = 1
+	case "0", "false":
+		rule.Public = 0
+	default:
+		return rule, errors.New("public 只能为 1 或 0")
+	}
+	return rule, nil
+}
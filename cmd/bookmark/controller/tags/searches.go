@@ -0,0 +1,105 @@
+package tags
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	model2 "bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// searches 保存的搜索, 包括其他账号共享的, 和标签一起显示. 书签列表通过 search=id 使用
+func (that Tags) searches(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if uid <= 0 {
+		apiV2.Error(w, r, errors.New("请先登录"))
+		return
+	}
+
+	api.Success(w, r, internal.NewSavedSearchesInternal().List(uid), "获取保存的搜索")
+}
+
+// saveSearch 新建或修改保存的搜索, id 为空时新建
+func (that Tags) saveSearch(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id     int32
+		Name   string `valid:"Required;"`
+		Query  string
+		Sort   string
+		Pinned bool
+		Shared bool
+		Uid    int
+	}{
+		Id:     body.PostInt32("id"),
+		Name:   body.PostString("name"),
+		Query:  body.PostString("q"),
+		Sort:   body.PostString("sort"),
+		Pinned: body.PostBool("pinned"),
+		Shared: body.PostBool("shared"),
+		Uid:    int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	searchesInternal := internal.NewSavedSearchesInternal()
+	search := model2.SavedSearchModel{
+		Uid:   u.Uid,
+		Name:  u.Name,
+		Query: u.Query,
+		Sort:  u.Sort,
+	}
+	if u.Id > 0 {
+		if old := searchesInternal.Info(int(u.Id)); old.ID <= 0 || old.Uid != u.Uid {
+			apiV2.Error(w, r, errors.New("搜索不存在"))
+			return
+		}
+		search.ID = int(u.Id)
+	}
+	if u.Pinned {
+		search.Pinned = 1
+	}
+	if u.Shared {
+		search.Shared = 1
+	}
+
+	search, err := searchesInternal.Save(search)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, search, "保存搜索")
+}
+
+// deleteSearch 删除保存的搜索
+func (that Tags) deleteSearch(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	searchesInternal := internal.NewSavedSearchesInternal()
+	if search := searchesInternal.Info(int(u.Id)); search.ID <= 0 || search.Uid != u.Uid {
+		apiV2.Error(w, r, errors.New("搜索不存在"))
+		return
+	}
+
+	searchesInternal.Delete(int(u.Id))
+	apiV2.Success(w, r, nil, "删除成功")
+}
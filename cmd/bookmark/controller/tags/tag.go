@@ -6,6 +6,7 @@ import (
 	"github.com/cute-angelia/go-utils/utils/http/apiV2"
 	"github.com/cute-angelia/go-utils/utils/http/validation"
 	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
 	"net/http"
 )
 
@@ -15,6 +16,17 @@ type Tags struct {
 func (that Tags) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Post("/", that.lists)
+	r.Post("/move", that.move)
+
+	r.Post("/searches", that.searches)
+	r.Post("/searches/save", that.saveSearch)
+	r.Post("/searches/delete", that.deleteSearch)
+
+	r.Post("/rules", that.rules)
+	r.Post("/rules/save", that.saveRule)
+	r.Post("/rules/delete", that.deleteRule)
+	r.Post("/rules/preview", that.previewRule)
+	r.Post("/rules/apply", that.applyRule)
 	return r
 }
 
@@ -35,3 +47,37 @@ func (that Tags) lists(w http.ResponseWriter, r *http.Request) {
 	api.Success(w, r, internal.NewTagInternal().GetList(), "获取书签列表")
 	return
 }
+
+// move 移动标签及其下级标签, parent 为 0 时移动到最上级, name 不为空时同时重命名, 仅管理员可用
+func (that Tags) move(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id     int32 `valid:"Required;"`
+		Parent int32
+		Name   string
+		Uid    int
+	}{
+		Id:     body.PostInt32("id"),
+		Parent: body.PostInt32("parent"),
+		Name:   body.PostString("name"),
+		Uid:    int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if !internal.IsOwnerAccount(u.Uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能移动标签"))
+		return
+	}
+
+	tag, err := internal.NewTagInternal().Move(int(u.Id), int(u.Parent), u.Name)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, tag, "移动标签")
+}
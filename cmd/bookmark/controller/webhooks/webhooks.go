@@ -0,0 +1,212 @@
+package webhooks
+
+import (
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/utils/http/api"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/cute-angelia/go-utils/utils/http/validation"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// 投递记录每次最多返回的数量
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// Webhooks 管理员注册的 webhook, 书签、标签和账号事件发生时发送签名的 JSON 请求
+type Webhooks struct {
+}
+
+func (that Webhooks) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Post("/", that.list)
+	r.Post("/save", that.save)
+	r.Post("/delete", that.delete)
+
+	r.Post("/deliveries", that.deliveries)
+	r.Post("/redeliver", that.redeliver)
+	return r
+}
+
+// list 全部 webhook 和可以订阅的事件
+func (that Webhooks) list(w http.ResponseWriter, r *http.Request) {
+	uid := int(apiV2.GetLoginUid(r))
+	if !internal.IsOwnerAccount(uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能管理 webhook"))
+		return
+	}
+
+	api.Success(w, r, map[string]interface{}{
+		"webhooks": internal.NewWebhooksInternal().List(),
+		"events":   model.WebhookEvents,
+	}, "获取 webhook")
+}
+
+// save 新建或修改 webhook, id 为空时新建. events 为逗号分隔的事件, * 为全部事件; secret 为空时新建随机生成, 修改时不变
+func (that Webhooks) save(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id      int32
+		Url     string `valid:"Required;"`
+		Events  string `valid:"Required;"`
+		Secret  string
+		Enabled string
+		Uid     int
+	}{
+		Id:      body.PostInt32("id"),
+		Url:     body.PostString("url"),
+		Events:  body.PostString("events"),
+		Secret:  body.PostString("secret"),
+		Enabled: body.PostString("enabled"),
+		Uid:     int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if !internal.IsOwnerAccount(u.Uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能管理 webhook"))
+		return
+	}
+
+	webhooksInternal := internal.NewWebhooksInternal()
+	hook := model.WebhookModel{
+		Uid:     u.Uid,
+		URL:     u.Url,
+		Events:  u.Events,
+		Secret:  u.Secret,
+		Enabled: 1,
+	}
+	if u.Id > 0 {
+		old := webhooksInternal.Info(int(u.Id))
+		if old.ID <= 0 {
+			apiV2.Error(w, r, errors.New("webhook 不存在"))
+			return
+		}
+		hook.ID, hook.Uid, hook.Enabled = old.ID, old.Uid, old.Enabled
+	}
+
+	// enabled 为空时新建的启用, 修改时保持不变
+	switch u.Enabled {
+	case "":
+	case "1", "true":
+		hook.Enabled = 1
+	case "0", "false":
+		hook.Enabled = 0
+	default:
+		apiV2.Error(w, r, errors.New("enabled 只能为 1 或 0"))
+		return
+	}
+
+	hook, err := webhooksInternal.Save(hook)
+	if err != nil {
+		apiV2.Error(w, r, err)
+		return
+	}
+	api.Success(w, r, hook, "保存 webhook")
+}
+
+// delete 删除 webhook 和投递记录
+func (that Webhooks) delete(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if !internal.IsOwnerAccount(u.Uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能管理 webhook"))
+		return
+	}
+
+	webhooksInternal := internal.NewWebhooksInternal()
+	if hook := webhooksInternal.Info(int(u.Id)); hook.ID <= 0 {
+		apiV2.Error(w, r, errors.New("webhook 不存在"))
+		return
+	}
+
+	webhooksInternal.Delete(int(u.Id))
+	apiV2.Success(w, r, nil, "删除成功")
+}
+
+// deliveries webhook 的投递记录, 从新到旧
+func (that Webhooks) deliveries(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id    int32 `valid:"Required;"`
+		Limit int32
+		Uid   int
+	}{
+		Id:    body.PostInt32("id"),
+		Limit: body.PostInt32("limit"),
+		Uid:   int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if !internal.IsOwnerAccount(u.Uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能管理 webhook"))
+		return
+	}
+
+	limit := int(u.Limit)
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	} else if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+
+	api.Success(w, r, internal.NewWebhooksInternal().Deliveries(int(u.Id), limit), "获取投递记录")
+}
+
+// redeliver 重新投递, 例如接收方修复后重发失败的事件
+func (that Webhooks) redeliver(w http.ResponseWriter, r *http.Request) {
+	// 校验参数
+	valid := validation.Validation{}
+	body := apiV2.NewBody(r)
+	u := struct {
+		Id  int32 `valid:"Required;"`
+		Uid int
+	}{
+		Id:  body.PostInt32("id"),
+		Uid: int(apiV2.GetLoginUid(r)),
+	}
+	if err := valid.Submit(u); err != nil {
+		api.Error(w, r, nil, err.Error(), -1)
+		return
+	}
+
+	if !internal.IsOwnerAccount(u.Uid) {
+		apiV2.Error(w, r, errors.New("非管理员不能管理 webhook"))
+		return
+	}
+
+	webhooksInternal := internal.NewWebhooksInternal()
+	if delivery := webhooksInternal.DeliveryInfo(int(u.Id)); delivery.ID <= 0 {
+		apiV2.Error(w, r, errors.New("投递记录不存在"))
+		return
+	}
+
+	webhooksInternal.Redeliver(int(u.Id))
+	apiV2.Success(w, r, nil, "已重新投递")
+}
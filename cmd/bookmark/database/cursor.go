@@ -0,0 +1,108 @@
+package database
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Cursor is an opaque position in a sorted bookmark list, the next page starts after it.
+// Unlike offsets it stays stable when bookmarks are added between page loads.
+type Cursor struct {
+	Order   OrderMethod `json:"o"`
+	Reverse bool        `json:"r,omitempty"`
+	Seed    int64       `json:"s,omitempty"`
+	Key     string      `json:"k,omitempty"` // value of the sort key of the last bookmark
+	ID      int         `json:"i"`
+	Offset  int         `json:"n,omitempty"` // bookmarks before the next page, for orders paged by offset
+}
+
+// NewCursor returns the cursor after the bookmark in a list sorted by the options,
+// offset is the number of bookmarks up to and including it.
+func NewCursor(opts GetBookmarksOptions, bookmark model2.BookmarkModel, offset int) Cursor {
+	cursor := Cursor{Order: opts.OrderMethod, Reverse: opts.Reverse, ID: bookmark.ID}
+	switch opts.OrderMethod {
+	case ByLastAdded:
+		cursor.Key = strconv.FormatInt(bookmark.CreatedAt, 10)
+	case ByLastModified:
+		cursor.Key = strconv.FormatInt(bookmark.ModifiedAt, 10)
+	case ByLastRead:
+		cursor.Key = bookmark.ReadAt
+	case ByLastArchived:
+		cursor.Key = bookmark.ArchivedAt
+	case ByLastFavorited:
+		cursor.Key = bookmark.FavoritedAt
+	case ByTitle:
+		cursor.Key = bookmark.Title
+	case ByDomain:
+		cursor.Key = hostOf(bookmark.URL)
+	case ByLastVisited:
+		cursor.Key = strconv.FormatInt(bookmark.VisitedAt, 10)
+	case ByRandom:
+		cursor.Seed = opts.Seed
+		cursor.Key = strconv.FormatInt(randomKey(bookmark.ID, opts.Seed), 10)
+	case ByRelevance:
+		cursor.Offset = offset
+	}
+	return cursor
+}
+
+// ParseCursor decodes a cursor returned by Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("cursor 无效")
+	}
+	cursor := &Cursor{}
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.ID <= 0 || cursor.Offset < 0 {
+		return nil, errors.New("cursor 无效")
+	}
+	if key := (GetBookmarksOptions{OrderMethod: cursor.Order}).orderKey(); key.numeric && !key.offset {
+		if _, err := strconv.ParseInt(cursor.Key, 10, 64); err != nil {
+			return nil, errors.New("cursor 无效")
+		}
+	}
+	return cursor, nil
+}
+
+// String encodes the cursor for clients.
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// CursorClause builds the where clause for bookmarks after opts.After, empty without a cursor
+// or when the order is paged by offset.
+func (opts GetBookmarksOptions) CursorClause() (string, []interface{}) {
+	if opts.After == nil {
+		return "", nil
+	}
+	key := opts.orderKey()
+	if key.offset {
+		return "", nil
+	}
+
+	cmp := " < "
+	if key.asc {
+		cmp = " > "
+	}
+	if key.expr == "" {
+		return "id" + cmp + "?", []interface{}{opts.After.ID}
+	}
+	var value interface{} = opts.After.Key
+	if key.numeric {
+		value, _ = strconv.ParseInt(opts.After.Key, 10, 64)
+	}
+	return "(" + key.expr + cmp + "? OR (" + key.expr + " = ? AND id" + cmp + "?))", []interface{}{value, value, opts.After.ID}
+}
+
+// CursorOffset is the offset of the next page for orders paged by offset.
+func (opts GetBookmarksOptions) CursorOffset() int {
+	if opts.After == nil || !opts.orderKey().offset {
+		return 0
+	}
+	return opts.After.Offset
+}
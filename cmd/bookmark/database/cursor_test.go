@@ -0,0 +1,89 @@
+package database
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	bookmark := model2.BookmarkModel{
+		ID:         42,
+		URL:        "https://www.Example.com/a",
+		Title:      "Go",
+		CreatedAt:  1690000000,
+		ModifiedAt: 1700000000,
+		VisitedAt:  1700000100,
+		ReadAt:     "2024-01-02 03:04:05",
+	}
+	added, modified := int64(1690000000), int64(1700000000)
+	random := randomKey(42, 7)
+
+	tests := []struct {
+		name    string
+		opts    GetBookmarksOptions
+		clause  string
+		args    []interface{}
+		offset  int
+		reverse bool
+	}{
+		{"default", GetBookmarksOptions{OrderMethod: DefaultOrder}, "id > ?", []interface{}{42}, 0, false},
+		{"added", GetBookmarksOptions{OrderMethod: ByLastAdded}, "(created_at < ? OR (created_at = ? AND id < ?))", []interface{}{added, added, 42}, 0, false},
+		{"added asc", GetBookmarksOptions{OrderMethod: ByLastAdded, Reverse: true}, "(created_at > ? OR (created_at = ? AND id > ?))", []interface{}{added, added, 42}, 0, true},
+		{"modified", GetBookmarksOptions{OrderMethod: ByLastModified}, "(modified_at < ? OR (modified_at = ? AND id < ?))", []interface{}{modified, modified, 42}, 0, false},
+		{"read", GetBookmarksOptions{OrderMethod: ByLastRead}, "(read_at < ? OR (read_at = ? AND id < ?))", []interface{}{"2024-01-02 03:04:05", "2024-01-02 03:04:05", 42}, 0, false},
+		{"title", GetBookmarksOptions{OrderMethod: ByTitle}, "(title COLLATE NOCASE > ? OR (title COLLATE NOCASE = ? AND id > ?))", []interface{}{"Go", "Go", 42}, 0, false},
+		{"title desc", GetBookmarksOptions{OrderMethod: ByTitle, Reverse: true}, "(title COLLATE NOCASE < ? OR (title COLLATE NOCASE = ? AND id < ?))", []interface{}{"Go", "Go", 42}, 0, true},
+		{"domain", GetBookmarksOptions{OrderMethod: ByDomain}, "(" + HostExpr + " > ? OR (" + HostExpr + " = ? AND id > ?))", []interface{}{"www.example.com", "www.example.com", 42}, 0, false},
+		{"random", GetBookmarksOptions{OrderMethod: ByRandom, Seed: 7}, "(((id * 8) % 2147483647) > ? OR (((id * 8) % 2147483647) = ? AND id > ?))", []interface{}{random, random, 42}, 0, false},
+		{"relevance", GetBookmarksOptions{OrderMethod: ByRelevance, Keyword: "go"}, "", nil, 30, false},
+	}
+	for _, tt := range tests {
+		cursor := NewCursor(tt.opts, bookmark, 30)
+		parsed, err := ParseCursor(cursor.String())
+		if err != nil {
+			t.Errorf("%s: ParseCursor: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*parsed, cursor) {
+			t.Errorf("%s: round trip %+v, want %+v", tt.name, *parsed, cursor)
+		}
+		if parsed.Reverse != tt.reverse {
+			t.Errorf("%s: reverse %v", tt.name, parsed.Reverse)
+		}
+
+		opts := tt.opts
+		opts.After = parsed
+		clause, args := opts.CursorClause()
+		if clause != tt.clause || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: CursorClause = %s %#v, want %s %#v", tt.name, clause, args, tt.clause, tt.args)
+		}
+		if offset := opts.CursorOffset(); offset != tt.offset {
+			t.Errorf("%s: CursorOffset = %d, want %d", tt.name, offset, tt.offset)
+		}
+	}
+
+	// 没有游标时不加条件
+	if clause, args := (GetBookmarksOptions{OrderMethod: ByLastModified}).CursorClause(); clause != "" || args != nil {
+		t.Errorf("CursorClause without cursor = %q, %v", clause, args)
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	for _, s := range []string{
+		"",
+		"!!!",
+		encode("not json"),
+		encode(`{"o":1}`),                 // 没有 id
+		encode(`{"o":2,"k":"abc","i":1}`), // 按修改时间排序的值不是数字
+		encode(`{"o":9,"n":-1,"i":1}`),    // 偏移为负数
+	} {
+		if cursor, err := ParseCursor(s); err == nil {
+			t.Errorf("ParseCursor(%q) = %+v", s, cursor)
+		}
+	}
+}
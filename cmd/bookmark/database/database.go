@@ -5,6 +5,8 @@ import (
 	"context"
 	"embed"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -23,20 +25,172 @@ const (
 	ByLastAdded
 	// ByLastModified is from latest modified to the oldest.
 	ByLastModified
+	// ByLastRead is from latest read to the oldest.
+	ByLastRead
+	// ByLastArchived is from latest archived to the oldest.
+	ByLastArchived
+	// ByLastFavorited is from latest favorited to the oldest.
+	ByLastFavorited
+	// ByTitle is by title from A to Z, ignoring case.
+	ByTitle
+	// ByDomain is by domain of the url from A to Z.
+	ByDomain
+	// ByLastVisited is from latest visited to the oldest.
+	ByLastVisited
+	// ByRelevance is from the best match of the keywords to the worst, newest first without keywords.
+	ByRelevance
+	// ByRandom is shuffled by the seed of the options.
+	ByRandom
 )
 
+// NoteKeywordClause matches bookmarks whose notes or highlights contain the keyword, takes 3 keyword args.
+const NoteKeywordClause = `id IN (SELECT bookmark_id FROM bookmark_note WHERE content LIKE ?)
+	OR id IN (SELECT bookmark_id FROM bookmark_highlight WHERE exact LIKE ? OR note LIKE ?)`
+
+// TagSubtreeClause selects bookmarks tagged with a tag or any of its descendants,
+// takes the tag path and TagDescendantPattern of the path.
+const TagSubtreeClause = `SELECT bt.bookmark_id FROM bookmark_tag bt JOIN tag t ON bt.tag_id = t.id
+	WHERE t.name = ? OR t.name LIKE ? ESCAPE '\'`
+
+// NormalizeTagPath trims every segment of a tag path and drops the empty ones, e.g. " lang / go/" becomes lang/go.
+func NormalizeTagPath(path string) string {
+	segments := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Join(strings.Fields(segment), " ")
+		if len(segment) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// TagDescendantPattern is the LIKE pattern matching the descendants of a tag path.
+func TagDescendantPattern(path string) string {
+	return escapeLike(path) + "/%"
+}
+
 // GetBookmarksOptions is options for fetching bookmarks from database.
 type GetBookmarksOptions struct {
 	IDs          []int
 	Tags         []string
+	AnyTags      bool // 满足 Tags 中任意一个即可, 默认需要全部满足
 	ExcludedTags []string
 	Keyword      string
+	Query        *Query // 搜索语句, 和其他条件同时满足
+	Status       string // unread, read, archived, 为空时不限
+	Favorite     bool   // 只返回收藏的书签
+	Domain       string // 域名, 包括子域名
+	AddedFrom    time.Time
+	AddedTo      time.Time // 添加时间范围 [AddedFrom, AddedTo), 零值时不限
+	ModifiedFrom time.Time
+	ModifiedTo   time.Time // 修改时间范围 [ModifiedFrom, ModifiedTo), 零值时不限
+	Screenshot   *bool     // 是否有截图, nil 时不限
+	Public       *bool     // 是否公开, nil 时不限
+	Uid          int       // 书签所有者, 为 0 时不限
 	WithContent  bool
 	OrderMethod  OrderMethod
+	Reverse      bool    // 和 OrderMethod 相反的方向
+	Seed         int64   // ByRandom 的随机种子, 相同的种子顺序相同
+	After        *Cursor // 只返回该位置之后的书签, 排序需要和 OrderMethod、Reverse 一致
 	Limit        int
 	Offset       int
 }
 
+// FieldFilters builds the where clause for the domain, time range, screenshot, public and owner filters,
+// shared by the sqlx and gorm list queries. Returns an empty clause when none is set.
+func (opts GetBookmarksOptions) FieldFilters() (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	if len(opts.Domain) > 0 {
+		clause, domainArgs := DomainClause(opts.Domain)
+		clauses = append(clauses, clause)
+		args = append(args, domainArgs...)
+	}
+
+	for _, r := range []struct {
+		column string
+		from   time.Time
+		to     time.Time
+	}{
+		{"created_at", opts.AddedFrom, opts.AddedTo},
+		{"modified_at", opts.ModifiedFrom, opts.ModifiedTo},
+	} {
+		if !r.from.IsZero() {
+			clauses = append(clauses, r.column+" >= ?")
+			args = append(args, r.from.Unix())
+		}
+		if !r.to.IsZero() {
+			clauses = append(clauses, r.column+" < ?")
+			args = append(args, r.to.Unix())
+		}
+	}
+
+	if opts.Screenshot != nil {
+		if *opts.Screenshot {
+			clauses = append(clauses, "image_url <> ''")
+		} else {
+			clauses = append(clauses, "image_url = ''")
+		}
+	}
+
+	if opts.Public != nil {
+		public := 0
+		if *opts.Public {
+			public = 1
+		}
+		clauses = append(clauses, "public = ?")
+		args = append(args, public)
+	}
+
+	if opts.Uid > 0 {
+		clauses = append(clauses, "uid = ?")
+		args = append(args, opts.Uid)
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// TagFilters builds the where clause for Tags and ExcludedTags on bookmark_tag, a tag also matches
+// its descendants. A * in ExcludedTags only keeps untagged bookmarks, otherwise a * in Tags only keeps
+// tagged bookmarks, other tags are ignored in both cases. Returns an empty clause without tags.
+func (opts GetBookmarksOptions) TagFilters() (string, []interface{}) {
+	for _, tag := range opts.ExcludedTags {
+		if tag == "*" {
+			return "id NOT IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)", nil
+		}
+	}
+	for _, tag := range opts.Tags {
+		if tag == "*" {
+			return "id IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)", nil
+		}
+	}
+
+	var included, clauses []string
+	var args []interface{}
+	for _, tag := range opts.Tags {
+		if tag = NormalizeTagPath(tag); len(tag) > 0 {
+			included = append(included, "id IN ("+TagSubtreeClause+")")
+			args = append(args, tag, TagDescendantPattern(tag))
+		}
+	}
+	if len(included) > 0 {
+		if opts.AnyTags {
+			clauses = append(clauses, "("+strings.Join(included, " OR ")+")")
+		} else {
+			clauses = append(clauses, included...)
+		}
+	}
+
+	for _, tag := range opts.ExcludedTags {
+		if tag = NormalizeTagPath(tag); len(tag) > 0 {
+			clauses = append(clauses, "id NOT IN ("+TagSubtreeClause+")")
+			args = append(args, tag, TagDescendantPattern(tag))
+		}
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
 // GetAccountsOptions is options for fetching accounts from database.
 type GetAccountsOptions struct {
 	Keyword string
@@ -54,6 +208,9 @@ type DB interface {
 	// Migrate runs migrations for this database
 	Migrate() error
 
+	// InitSearchIndex creates the full text index, search falls back to LIKE when it fails.
+	InitSearchIndex(ctx context.Context) error
+
 	// SaveBookmarks saves bookmarks data to database.
 	SaveBookmarks(ctx context.Context, create bool, bookmarks ...model2.BookmarkModel) ([]model2.BookmarkModel, error)
 
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS bookmark_content(
+    docid INTEGER NOT NULL,
+    title TEXT NOT NULL DEFAULT "",
+    content TEXT NOT NULL DEFAULT "",
+    html TEXT NOT NULL DEFAULT "",
+    CONSTRAINT bookmark_content_PK PRIMARY KEY(docid),
+    CONSTRAINT bookmark_content_docid_FK FOREIGN KEY(docid) REFERENCES bookmark(id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE bookmark ADD COLUMN author TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN published TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN site_name TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN favicon TEXT NOT NULL DEFAULT "";
@@ -0,0 +1,3 @@
+ALTER TABLE bookmark ADD COLUMN content_type TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN file_name TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0;
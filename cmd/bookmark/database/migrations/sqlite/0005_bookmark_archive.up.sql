@@ -0,0 +1 @@
+ALTER TABLE bookmark ADD COLUMN archive TEXT NOT NULL DEFAULT "";
@@ -0,0 +1 @@
+ALTER TABLE bookmark ADD COLUMN warc TEXT NOT NULL DEFAULT "";
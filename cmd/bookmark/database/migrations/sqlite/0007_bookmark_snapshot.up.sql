@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS bookmark_snapshot(
+    id INTEGER PRIMARY KEY Autoincrement,
+    bookmark_id INTEGER NOT NULL,
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    title TEXT NOT NULL DEFAULT "",
+    excerpt TEXT NOT NULL DEFAULT "",
+    author TEXT NOT NULL DEFAULT "",
+    published TEXT NOT NULL DEFAULT "",
+    site_name TEXT NOT NULL DEFAULT "",
+    content_type TEXT NOT NULL DEFAULT "",
+    image_url TEXT NOT NULL DEFAULT "",
+    archive TEXT NOT NULL DEFAULT "",
+    warc TEXT NOT NULL DEFAULT "",
+    content TEXT NOT NULL DEFAULT "",
+    CONSTRAINT bookmark_snapshot_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
+
+CREATE INDEX IF NOT EXISTS bookmark_snapshot_bookmark_id_IDX ON bookmark_snapshot(bookmark_id);
@@ -0,0 +1,7 @@
+ALTER TABLE bookmark ADD COLUMN status TEXT NOT NULL DEFAULT "unread";
+ALTER TABLE bookmark ADD COLUMN favorite INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE bookmark ADD COLUMN read_at TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN archived_at TEXT NOT NULL DEFAULT "";
+ALTER TABLE bookmark ADD COLUMN favorited_at TEXT NOT NULL DEFAULT "";
+
+CREATE INDEX IF NOT EXISTS bookmark_status_idx ON bookmark(status);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS bookmark_note(
+    id INTEGER PRIMARY KEY Autoincrement,
+    bookmark_id INTEGER NOT NULL,
+    uid INTEGER NOT NULL DEFAULT 0,
+    content TEXT NOT NULL DEFAULT "",
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT bookmark_note_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
+
+CREATE INDEX IF NOT EXISTS bookmark_note_bookmark_id_IDX ON bookmark_note(bookmark_id);
+
+CREATE TABLE IF NOT EXISTS bookmark_highlight(
+    id INTEGER PRIMARY KEY Autoincrement,
+    bookmark_id INTEGER NOT NULL,
+    snapshot_id INTEGER NOT NULL DEFAULT 0,
+    uid INTEGER NOT NULL DEFAULT 0,
+    exact TEXT NOT NULL,
+    prefix TEXT NOT NULL DEFAULT "",
+    suffix TEXT NOT NULL DEFAULT "",
+    position_start INTEGER NOT NULL DEFAULT -1,
+    position_end INTEGER NOT NULL DEFAULT -1,
+    color TEXT NOT NULL DEFAULT "",
+    note TEXT NOT NULL DEFAULT "",
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT bookmark_highlight_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
+
+CREATE INDEX IF NOT EXISTS bookmark_highlight_bookmark_id_IDX ON bookmark_highlight(bookmark_id);
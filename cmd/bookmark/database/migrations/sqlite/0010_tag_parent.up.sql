@@ -0,0 +1,20 @@
+ALTER TABLE tag ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS tag_parent_id_IDX ON tag(parent_id);
+
+-- 标签名为完整路径 (lang/go), 补全已有标签缺少的上级标签
+WITH RECURSIVE prefixes(path, rest) AS (
+    SELECT '', name || '/' FROM tag WHERE instr(name, '/') > 0
+    UNION ALL
+    SELECT CASE WHEN path = '' THEN '' ELSE path || '/' END || substr(rest, 1, instr(rest, '/') - 1),
+           substr(rest, instr(rest, '/') + 1)
+    FROM prefixes WHERE rest <> ''
+)
+INSERT OR IGNORE INTO tag(name) SELECT DISTINCT path FROM prefixes WHERE path <> '' AND instr(path, '//') = 0;
+
+UPDATE tag SET parent_id = COALESCE((
+    SELECT p.id FROM tag p
+    WHERE length(tag.name) > length(p.name) + 1
+      AND substr(tag.name, 1, length(p.name) + 1) = p.name || '/'
+      AND instr(substr(tag.name, length(p.name) + 2), '/') = 0
+), 0);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS saved_search(
+    id INTEGER PRIMARY KEY Autoincrement,
+    uid INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL DEFAULT "",
+    sort TEXT NOT NULL DEFAULT "",
+    pinned INTEGER NOT NULL DEFAULT 0,
+    shared INTEGER NOT NULL DEFAULT 0,
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT saved_search_uid_name_UNIQUE UNIQUE(uid, name)
+);
@@ -0,0 +1,14 @@
+ALTER TABLE bookmark ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE bookmark ADD COLUMN modified_at INTEGER NOT NULL DEFAULT 0;
+
+-- modified 是本地时间的字符串, 转换为 unix 时间戳; 添加时间取 modified 和最早快照中较早的
+UPDATE bookmark SET modified_at = IFNULL(CAST(strftime('%s', modified, 'utc') AS INTEGER), 0);
+
+UPDATE bookmark SET created_at = MIN(modified_at, IFNULL((
+    SELECT CAST(strftime('%s', MIN(s.created), 'utc') AS INTEGER) FROM bookmark_snapshot s WHERE s.bookmark_id = bookmark.id
+), modified_at));
+
+CREATE INDEX IF NOT EXISTS bookmark_created_at_IDX ON bookmark(created_at);
+
+CREATE INDEX IF NOT EXISTS bookmark_modified_at_IDX ON bookmark(modified_at);
@@ -0,0 +1,3 @@
+ALTER TABLE bookmark ADD COLUMN visited_at INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS bookmark_visited_at_IDX ON bookmark(visited_at);
@@ -0,0 +1,16 @@
+-- bookmark.tags 是 bookmark_tag 中标签 id 的冗余, 逗号分隔, 按添加顺序. 由触发器维护, 程序不再写入
+UPDATE bookmark SET tags = IFNULL((
+    SELECT group_concat(tag_id, ',') FROM (SELECT tag_id FROM bookmark_tag WHERE bookmark_id = bookmark.id ORDER BY rowid)
+), '');
+
+CREATE TRIGGER IF NOT EXISTS bookmark_tag_sync_ai AFTER INSERT ON bookmark_tag BEGIN
+    UPDATE bookmark SET tags = IFNULL((
+        SELECT group_concat(tag_id, ',') FROM (SELECT tag_id FROM bookmark_tag WHERE bookmark_id = new.bookmark_id ORDER BY rowid)
+    ), '') WHERE id = new.bookmark_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_tag_sync_ad AFTER DELETE ON bookmark_tag BEGIN
+    UPDATE bookmark SET tags = IFNULL((
+        SELECT group_concat(tag_id, ',') FROM (SELECT tag_id FROM bookmark_tag WHERE bookmark_id = old.bookmark_id ORDER BY rowid)
+    ), '') WHERE id = old.bookmark_id;
+END;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS tag_rule(
+    id INTEGER PRIMARY KEY Autoincrement,
+    uid INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL DEFAULT "",
+    match_type TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    tags TEXT NOT NULL DEFAULT "",
+    public INTEGER NOT NULL DEFAULT -1,
+    status TEXT NOT NULL DEFAULT "",
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS tag_rule_uid_IDX ON tag_rule(uid);
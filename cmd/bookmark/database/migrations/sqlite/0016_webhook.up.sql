@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS webhook(
+    id INTEGER PRIMARY KEY Autoincrement,
+    uid INTEGER NOT NULL DEFAULT 0,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT "",
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery(
+    id INTEGER PRIMARY KEY Autoincrement,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT "pending",
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT NOT NULL DEFAULT "",
+    error TEXT NOT NULL DEFAULT "",
+    next_attempt_at INTEGER NOT NULL DEFAULT 0,
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_webhook_id_IDX ON webhook_delivery(webhook_id);
+CREATE INDEX IF NOT EXISTS webhook_delivery_status_IDX ON webhook_delivery(status, next_attempt_at);
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ByRandom shuffles ids with (id * seed) % randomModulus, a different permutation for each seed.
+const randomModulus = 2147483647
+
+// orderKey is the expression sorted on before id.
+type orderKey struct {
+	expr    string // empty when sorted by id only
+	args    []interface{}
+	numeric bool
+	asc     bool
+	offset  bool // paged by offset, the key is not stable enough for a cursor
+}
+
+func (opts GetBookmarksOptions) orderKey() orderKey {
+	var key orderKey
+	switch opts.OrderMethod {
+	case DefaultOrder:
+		key.asc = true
+	case ByLastAdded:
+		key.expr, key.numeric = "created_at", true
+	case ByLastModified:
+		key.expr, key.numeric = "modified_at", true
+	case ByLastRead:
+		key.expr = "read_at"
+	case ByLastArchived:
+		key.expr = "archived_at"
+	case ByLastFavorited:
+		key.expr = "favorited_at"
+	case ByTitle:
+		key.expr, key.asc = "title COLLATE NOCASE", true
+	case ByDomain:
+		key.expr, key.asc = HostExpr, true
+	case ByLastVisited:
+		key.expr, key.numeric = "visited_at", true
+	case ByRandom:
+		key.expr = fmt.Sprintf("((id * %d) %% %d)", randomSeed(opts.Seed), randomModulus)
+		key.numeric, key.asc = true, true
+	case ByRelevance:
+		key.expr, key.args = opts.relevance()
+		key.numeric, key.offset = true, true
+	}
+	if opts.Reverse {
+		key.asc = !key.asc
+	}
+	return key
+}
+
+// OrderClause is the order by clause and its args, ties are broken by id in the same direction.
+func (opts GetBookmarksOptions) OrderClause() (string, []interface{}) {
+	key := opts.orderKey()
+	dir := " DESC"
+	if key.asc {
+		dir = " ASC"
+	}
+	if key.expr == "" {
+		return "id" + dir, nil
+	}
+	return key.expr + dir + ", id" + dir, key.args
+}
+
+// relevance scores the keywords of Query and Keyword, higher is better. Matches in the title
+// count more than in the url and excerpt, the full text index adds bm25 of the archived content.
+// Returns an empty expression without keywords.
+func (opts GetBookmarksOptions) relevance() (string, []interface{}) {
+	keywords := opts.Query.Keywords()
+	if keyword := strings.TrimSpace(opts.Keyword); len(keyword) > 0 {
+		keywords = append(keywords, keyword)
+	}
+	if len(keywords) == 0 {
+		return "", nil
+	}
+
+	var parts, matches []string
+	var args []interface{}
+	for _, keyword := range keywords {
+		like := "%" + escapeLike(keyword) + "%"
+		parts = append(parts, `CASE WHEN title LIKE ? ESCAPE '\' THEN 3 ELSE 0 END`,
+			`CASE WHEN url LIKE ? ESCAPE '\' THEN 2 ELSE 0 END`,
+			`CASE WHEN excerpt LIKE ? ESCAPE '\' THEN 1 ELSE 0 END`)
+		args = append(args, like, like, like)
+		if searchIndexEnabled && utf8.RuneCountInString(keyword) >= 3 {
+			matches = append(matches, ftsPhrase(keyword))
+		}
+	}
+	if len(matches) > 0 {
+		// bm25 越小越相关
+		parts = append(parts, `-IFNULL((SELECT bm25(bookmark_fts) FROM bookmark_fts WHERE bookmark_fts MATCH ? AND rowid = id), 0)`)
+		args = append(args, strings.Join(matches, " OR "))
+	}
+	return "(" + strings.Join(parts, " + ") + ")", args
+}
+
+// randomSeed keeps the seed in [1, randomModulus) so that the keys are distinct and don't overflow.
+func randomSeed(seed int64) int64 {
+	seed %= randomModulus - 1
+	if seed < 0 {
+		seed += randomModulus - 1
+	}
+	return seed + 1
+}
+
+// randomKey is the ByRandom key of the bookmark id.
+func randomKey(id int, seed int64) int64 {
+	return int64(id) * randomSeed(seed) % randomModulus
+}
+
+// hostOf is the go version of HostExpr.
+func hostOf(url string) string {
+	runes := []rune(url)
+	start := 2
+	if i := strings.Index(url, "://"); i >= 0 {
+		start = utf8.RuneCountInString(url[:i]) + 3
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+	host := string(runes[start:])
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	// SQLite lower 只转换 ASCII
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, host)
+}
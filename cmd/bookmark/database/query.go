@@ -0,0 +1,486 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed search query, for example:
+//
+//	go "error handling" tag:lang -site:example.com (is:unread OR is:favorite) after:2024-01
+//
+// Terms and quoted phrases match title, url, excerpt, archived content, notes and highlights.
+// Conditions are joined with AND unless separated by OR, `-` or NOT negates a condition.
+type Query struct {
+	Raw  string
+	root queryNode
+}
+
+// QueryError is a syntax error in a search query, Pos is the character offset.
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("第 %d 个字符: %s", e.Pos+1, e.Msg)
+}
+
+// 支持的筛选条件
+const (
+	queryFieldTag    = "tag"
+	queryFieldSite   = "site"
+	queryFieldIs     = "is"
+	queryFieldBefore = "before"
+	queryFieldAfter  = "after"
+	queryFieldPublic = "public"
+)
+
+var queryFields = map[string]bool{
+	queryFieldTag:    true,
+	queryFieldSite:   true,
+	queryFieldIs:     true,
+	queryFieldBefore: true,
+	queryFieldAfter:  true,
+	queryFieldPublic: true,
+}
+
+// ParseQuery parses a search query, an empty query returns nil.
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens, end: utf8.RuneCountInString(s)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, &QueryError{Pos: t.pos, Msg: "多余的右括号"}
+	}
+	return &Query{Raw: s, root: root}, nil
+}
+
+// SQL compiles the query into a where clause on the bookmark table and its args.
+func (q *Query) SQL() (string, []interface{}) {
+	if q == nil || q.root == nil {
+		return "", nil
+	}
+	c := &queryCompiler{fts: searchIndexEnabled}
+	return q.root.sql(c), c.args
+}
+
+// Keywords returns the terms and phrases of the query that are not negated.
+func (q *Query) Keywords() []string {
+	if q == nil || q.root == nil {
+		return nil
+	}
+	return queryKeywords(q.root, nil)
+}
+
+func queryKeywords(node queryNode, keywords []string) []string {
+	switch n := node.(type) {
+	case andNode:
+		for _, child := range n {
+			keywords = queryKeywords(child, keywords)
+		}
+	case orNode:
+		keywords = queryKeywords(n[1], queryKeywords(n[0], keywords))
+	case termNode:
+		if len(n.field) == 0 {
+			keywords = append(keywords, n.value)
+		}
+	}
+	return keywords
+}
+
+// 词法
+
+type queryTokenKind int
+
+const (
+	tokenTerm queryTokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenOr
+	tokenAnd
+	tokenNot
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	pos   int    // 字符位置
+	field string // 为空时是关键字
+	value string
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	runes := []rune(s)
+	var tokens []queryToken
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, pos: i})
+			i++
+		case r == '-':
+			if i+1 >= len(runes) || unicode.IsSpace(runes[i+1]) || runes[i+1] == ')' {
+				return nil, &QueryError{Pos: i, Msg: "- 后面缺少条件"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenNot, pos: i})
+			i++
+		case r == '"':
+			value, next, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokenTerm, pos: i, value: value})
+			i = next
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			word := string(runes[start:i])
+
+			switch word {
+			case "OR":
+				tokens = append(tokens, queryToken{kind: tokenOr, pos: start})
+				continue
+			case "AND":
+				tokens = append(tokens, queryToken{kind: tokenAnd, pos: start})
+				continue
+			case "NOT":
+				tokens = append(tokens, queryToken{kind: tokenNot, pos: start})
+				continue
+			}
+
+			token := queryToken{kind: tokenTerm, pos: start, value: word}
+			if field, value, ok := strings.Cut(word, ":"); ok && queryFields[strings.ToLower(field)] {
+				token.field, token.value = strings.ToLower(field), value
+				// tag:"my tag"
+				if len(value) == 0 && i < len(runes) && runes[i] == '"' {
+					quoted, next, err := lexQuoted(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					token.value, i = quoted, next
+				}
+				if len(strings.TrimSpace(token.value)) == 0 {
+					return nil, &QueryError{Pos: start, Msg: field + ": 缺少值"}
+				}
+			} else if i < len(runes) && runes[i] == '"' {
+				return nil, &QueryError{Pos: i, Msg: "引号前缺少空格"}
+			}
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// lexQuoted 读取引号中的内容, 返回内容和引号之后的位置
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] == '"' {
+			value := string(runes[start+1 : i])
+			if len(strings.TrimSpace(value)) == 0 {
+				return "", 0, &QueryError{Pos: start, Msg: "引号中没有内容"}
+			}
+			return value, i + 1, nil
+		}
+	}
+	return "", 0, &QueryError{Pos: start, Msg: "引号没有闭合"}
+}
+
+// 语法: or = and {"OR" and}; and = unary {["AND"] unary}; unary = ("-" | "NOT") unary | "(" or ")" | term
+
+type queryParser struct {
+	tokens []queryToken
+	i      int
+	end    int
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.i < len(p.tokens) {
+		return &p.tokens[p.i]
+	}
+	return nil
+}
+
+// pos 当前位置, 用于错误提示
+func (p *queryParser) pos() int {
+	if t := p.peek(); t != nil {
+		return t.pos
+	}
+	return p.end
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.kind == tokenOr; t = p.peek() {
+		p.i++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes andNode
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokenRParen || t.kind == tokenOr {
+			break
+		}
+		if t.kind == tokenAnd {
+			p.i++
+			if next := p.peek(); next == nil || next.kind == tokenRParen || next.kind == tokenOr || next.kind == tokenAnd {
+				return nil, &QueryError{Pos: t.pos, Msg: "AND 后面缺少条件"}
+			}
+			if len(nodes) == 0 {
+				return nil, &QueryError{Pos: t.pos, Msg: "AND 前面缺少条件"}
+			}
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	switch len(nodes) {
+	case 0:
+		if t := p.peek(); t != nil && t.kind == tokenOr {
+			return nil, &QueryError{Pos: t.pos, Msg: "OR 前面缺少条件"}
+		}
+		if p.i > 0 && p.tokens[p.i-1].kind == tokenOr {
+			return nil, &QueryError{Pos: p.tokens[p.i-1].pos, Msg: "OR 后面缺少条件"}
+		}
+		return nil, &QueryError{Pos: p.pos(), Msg: "缺少条件"}
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNot:
+		p.i++
+		if next := p.peek(); next == nil || next.kind == tokenRParen || next.kind == tokenOr || next.kind == tokenAnd {
+			return nil, &QueryError{Pos: t.pos, Msg: "NOT 后面缺少条件"}
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	case tokenLParen:
+		p.i++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokenRParen {
+			return nil, &QueryError{Pos: t.pos, Msg: "缺少右括号"}
+		}
+		p.i++
+		return node, nil
+	}
+
+	p.i++
+	return newTermNode(*t)
+}
+
+// 语法树
+
+type queryNode interface {
+	sql(c *queryCompiler) string
+}
+
+type queryCompiler struct {
+	fts  bool
+	args []interface{}
+}
+
+func (c *queryCompiler) add(clause string, args ...interface{}) string {
+	c.args = append(c.args, args...)
+	return clause
+}
+
+type andNode []queryNode
+
+func (n andNode) sql(c *queryCompiler) string {
+	parts := make([]string, 0, len(n))
+	for _, child := range n {
+		parts = append(parts, child.sql(c))
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+type orNode [2]queryNode
+
+func (n orNode) sql(c *queryCompiler) string {
+	return "(" + n[0].sql(c) + " OR " + n[1].sql(c) + ")"
+}
+
+type notNode struct {
+	child queryNode
+}
+
+func (n notNode) sql(c *queryCompiler) string {
+	return "NOT (" + n.child.sql(c) + ")"
+}
+
+type termNode struct {
+	field string
+	value string
+	at    int64 // before、after 的时间戳
+}
+
+// newTermNode 校验筛选条件的值
+func newTermNode(t queryToken) (queryNode, error) {
+	n := termNode{field: t.field, value: strings.TrimSpace(t.value)}
+	invalid := func(msg string) (queryNode, error) {
+		return nil, &QueryError{Pos: t.pos, Msg: msg}
+	}
+
+	switch n.field {
+	case queryFieldTag:
+		if n.value != "*" {
+			n.value = NormalizeTagPath(n.value)
+			if len(n.value) == 0 {
+				return invalid("tag: 缺少标签名")
+			}
+		}
+	case queryFieldSite:
+		n.value = NormalizeDomain(n.value)
+		if len(n.value) == 0 || strings.ContainsAny(n.value, "/?#") {
+			return invalid("site: 只能是域名, 例如 site:example.com")
+		}
+	case queryFieldIs:
+		n.value = strings.ToLower(n.value)
+		switch n.value {
+		case "unread", "read", "archived", "favorite", "fav", "public", "private", "tagged", "untagged":
+		default:
+			return invalid("is: 只能是 unread、read、archived、favorite、public、private、tagged 或 untagged")
+		}
+	case queryFieldPublic:
+		switch strings.ToLower(n.value) {
+		case "true", "yes", "1":
+			n.value = "1"
+		case "false", "no", "0":
+			n.value = "0"
+		default:
+			return invalid("public: 只能是 true 或 false")
+		}
+	case queryFieldBefore, queryFieldAfter:
+		ok := false
+		for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+			if t, err := time.ParseInLocation(layout, n.value, time.Local); err == nil {
+				n.at, ok = t.Unix(), true
+				break
+			}
+		}
+		if !ok {
+			return invalid(n.field + ": 日期格式应为 2006-01-02、2006-01 或 2006")
+		}
+	}
+	return n, nil
+}
+
+// NormalizeDomain lowercases a domain and strips the scheme, trailing slash and www. prefix.
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
+	}
+	return strings.TrimPrefix(strings.TrimRight(domain, "/"), "www.")
+}
+
+// DomainClause matches bookmarks on the domain or its subdomains.
+func DomainClause(domain string) (string, []interface{}) {
+	domain = NormalizeDomain(domain)
+	return "(" + HostExpr + " = ? OR " + HostExpr + " LIKE ? ESCAPE '\\')", []interface{}{domain, "%." + escapeLike(domain)}
+}
+
+// HostExpr is the lowercased host of the bookmark url.
+const HostExpr = `lower(CASE WHEN instr(substr(url, instr(url, '://') + 3), '/') > 0
+	THEN substr(substr(url, instr(url, '://') + 3), 1, instr(substr(url, instr(url, '://') + 3), '/') - 1)
+	ELSE substr(url, instr(url, '://') + 3) END)`
+
+func (n termNode) sql(c *queryCompiler) string {
+	switch n.field {
+	case queryFieldTag:
+		if n.value == "*" {
+			return "id IN (SELECT bookmark_id FROM bookmark_tag)"
+		}
+		return c.add("id IN ("+TagSubtreeClause+")", n.value, TagDescendantPattern(n.value))
+	case queryFieldSite:
+		clause, args := DomainClause(n.value)
+		return c.add(clause, args...)
+	case queryFieldIs:
+		switch n.value {
+		case "favorite", "fav":
+			return "favorite = 1"
+		case "public":
+			return "public = 1"
+		case "private":
+			return "public = 0"
+		case "tagged":
+			return "id IN (SELECT bookmark_id FROM bookmark_tag)"
+		case "untagged":
+			return "id NOT IN (SELECT bookmark_id FROM bookmark_tag)"
+		}
+		return c.add("status = ?", n.value)
+	case queryFieldPublic:
+		return "public = " + n.value
+	case queryFieldBefore:
+		return c.add("created_at < ?", n.at)
+	case queryFieldAfter:
+		return c.add("created_at >= ?", n.at)
+	}
+
+	// 关键字, 全文索引 (trigram) 要求至少 3 个字符, 较短的关键字使用 LIKE
+	like := "%" + escapeLike(n.value) + "%"
+	notes := `id IN (SELECT bookmark_id FROM bookmark_note WHERE content LIKE ? ESCAPE '\')
+		OR id IN (SELECT bookmark_id FROM bookmark_highlight WHERE exact LIKE ? ESCAPE '\' OR note LIKE ? ESCAPE '\')`
+	if c.fts && utf8.RuneCountInString(n.value) >= 3 {
+		return c.add("(id IN (SELECT rowid FROM bookmark_fts WHERE bookmark_fts MATCH ?) OR "+notes+")",
+			ftsPhrase(n.value), like, like, like)
+	}
+	return c.add(`(title LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\' OR excerpt LIKE ? ESCAPE '\'
+		OR id IN (SELECT docid FROM bookmark_content WHERE content LIKE ? ESCAPE '\') OR `+notes+")",
+		like, like, like, like, like, like, like)
+}
+
+// ftsPhrase 全文索引中的短语
+func ftsPhrase(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// escapeLike 转义 LIKE 中的通配符, 配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
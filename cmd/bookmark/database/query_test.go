@@ -0,0 +1,147 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"a AND", 2, "AND 后面缺少条件"},
+		{"AND a", 0, "AND 前面缺少条件"},
+		{"a AND AND b", 2, "AND 后面缺少条件"},
+		{"(a", 0, "缺少右括号"},
+		{"a)", 1, "多余的右括号"},
+		{"()", 1, "缺少条件"},
+		{"-", 0, "- 后面缺少条件"},
+		{"a - b", 2, "- 后面缺少条件"},
+		{"中文 -", 3, "- 后面缺少条件"},
+		{"NOT", 0, "NOT 后面缺少条件"},
+		{"a OR", 2, "OR 后面缺少条件"},
+		{"OR a", 0, "OR 前面缺少条件"},
+		{"tag:", 0, "tag: 缺少值"},
+		{`tag:" "`, 4, "引号中没有内容"},
+		{`"abc`, 0, "引号没有闭合"},
+		{`a"b"`, 1, "引号前缺少空格"},
+		{"is:foo", 0, "is: 只能是 unread、read、archived、favorite、public、private、tagged 或 untagged"},
+		{"site:example.com/a", 0, "site: 只能是域名, 例如 site:example.com"},
+		{"x before:2024-13", 2, "before: 日期格式应为 2006-01-02、2006-01 或 2006"},
+		{"public:maybe", 0, "public: 只能是 true 或 false"},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		queryErr, ok := err.(*QueryError)
+		if !ok {
+			t.Errorf("ParseQuery(%q) = %v, %v, want error", tt.query, q, err)
+			continue
+		}
+		if queryErr.Pos != tt.pos || queryErr.Msg != tt.msg {
+			t.Errorf("ParseQuery(%q) error at %d %q, want at %d %q", tt.query, queryErr.Pos, queryErr.Msg, tt.pos, tt.msg)
+		}
+	}
+
+	if err := (&QueryError{Pos: 3, Msg: "缺少条件"}); err.Error() != "第 4 个字符: 缺少条件" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestParseQueryEmpty(t *testing.T) {
+	for _, s := range []string{"", "   "} {
+		q, err := ParseQuery(s)
+		if q != nil || err != nil {
+			t.Errorf("ParseQuery(%q) = %v, %v", s, q, err)
+		}
+		if clause, args := q.SQL(); clause != "" || args != nil {
+			t.Errorf("nil query SQL = %q, %v", clause, args)
+		}
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local).Unix()
+	like := `%a\_b%`
+
+	tests := []struct {
+		query  string
+		clause string
+		args   []interface{}
+	}{
+		{
+			query:  "tag:Lang/Go",
+			clause: "id IN (" + TagSubtreeClause + ")",
+			args:   []interface{}{"Lang/Go", `Lang/Go/%`},
+		},
+		{
+			query:  "is:unread -site:www.Example.com",
+			clause: "(status = ? AND NOT ((" + HostExpr + " = ? OR " + HostExpr + ` LIKE ? ESCAPE '\')))`,
+			args:   []interface{}{"unread", "example.com", "%.example.com"},
+		},
+		{
+			query:  "is:favorite OR after:2024-01 public:yes",
+			clause: "(favorite = 1 OR (created_at >= ? AND public = 1))",
+			args:   []interface{}{after},
+		},
+		{
+			query:  `(tag:"my tag" OR tag:*) AND is:read`,
+			clause: "((id IN (" + TagSubtreeClause + ") OR id IN (SELECT bookmark_id FROM bookmark_tag)) AND status = ?)",
+			args:   []interface{}{"my tag", "my tag/%", "read"},
+		},
+		{
+			query: `NOT "a_b"`,
+			args:  []interface{}{like, like, like, like, like, like, like},
+		},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		clause, args := q.SQL()
+		if tt.clause != "" && clause != tt.clause {
+			t.Errorf("%q SQL:\n got %s\nwant %s", tt.query, clause, tt.clause)
+		}
+		if strings.Count(clause, "?") != len(args) {
+			t.Errorf("%q has %d placeholders and %d args", tt.query, strings.Count(clause, "?"), len(args))
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q args = %#v, want %#v", tt.query, args, tt.args)
+		}
+	}
+}
+
+func TestQuerySQLFullText(t *testing.T) {
+	searchIndexEnabled = true
+	defer func() { searchIndexEnabled = false }()
+
+	q, err := ParseQuery(`ab "say ""hi""" -xyz`)
+	if err == nil {
+		t.Fatalf("doubled quotes parsed as %q", q.Raw)
+	}
+
+	q, err = ParseQuery(`ab golang -rust`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clause, args := q.SQL()
+	want := []interface{}{
+		"%ab%", "%ab%", "%ab%", "%ab%", "%ab%", "%ab%", "%ab%", // 少于 3 个字符使用 LIKE
+		`"golang"`, "%golang%", "%golang%", "%golang%",
+		`"rust"`, "%rust%", "%rust%", "%rust%",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v", args)
+	}
+	if strings.Count(clause, "?") != len(args) || strings.Count(clause, "bookmark_fts MATCH ?") != 2 || !strings.Contains(clause, "NOT (") {
+		t.Errorf("clause = %s", clause)
+	}
+	if keywords := q.Keywords(); !reflect.DeepEqual(keywords, []string{"ab", "golang"}) {
+		t.Errorf("Keywords() = %v", keywords)
+	}
+}
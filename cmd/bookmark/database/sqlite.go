@@ -4,7 +4,6 @@ import (
 	model2 "bookmark/cmd/bookmark/model"
 	"context"
 	"database/sql"
-	"github.com/cute-angelia/go-utils/syntax/itime"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
@@ -13,6 +12,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"log"
 	"strings"
+	"time"
 )
 
 // SQLiteDatabase is implementation of Database interface
@@ -32,6 +32,32 @@ type tagContent struct {
 	model2.TagModel
 }
 
+// searchIndexEnabled is set when the full text index bookmark_fts is ready.
+var searchIndexEnabled bool
+
+// searchIndexSchema 全文索引及同步触发器. trigram 分词支持中文和子串匹配
+var searchIndexSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS bookmark_fts USING fts5(title, url, excerpt, content, tokenize = 'trigram')`,
+	`CREATE TRIGGER IF NOT EXISTS bookmark_fts_ai AFTER INSERT ON bookmark BEGIN
+		INSERT OR REPLACE INTO bookmark_fts(rowid, title, url, excerpt, content) VALUES (new.id, new.title, new.url, new.excerpt, '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bookmark_fts_au AFTER UPDATE OF title, url, excerpt ON bookmark BEGIN
+		UPDATE bookmark_fts SET title = new.title, url = new.url, excerpt = new.excerpt WHERE rowid = new.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bookmark_fts_ad AFTER DELETE ON bookmark BEGIN
+		DELETE FROM bookmark_fts WHERE rowid = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bookmark_content_fts_ai AFTER INSERT ON bookmark_content BEGIN
+		UPDATE bookmark_fts SET content = new.content WHERE rowid = new.docid;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bookmark_content_fts_au AFTER UPDATE OF content ON bookmark_content BEGIN
+		UPDATE bookmark_fts SET content = new.content WHERE rowid = new.docid;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bookmark_content_fts_ad AFTER DELETE ON bookmark_content BEGIN
+		UPDATE bookmark_fts SET content = '' WHERE rowid = old.docid;
+	END`,
+}
+
 // OpenSQLiteDatabase creates and open connection to new SQLite3 database.
 func OpenSQLiteDatabase(ctx context.Context, databasePath string) (sqliteDB *SQLiteDatabase, err error) {
 	// Open database
@@ -73,6 +99,35 @@ func (db *SQLiteDatabase) Migrate() error {
 	return nil
 }
 
+// InitSearchIndex creates the full text index used by search queries. It is not a migration
+// because SQLite builds without fts5 or the trigram tokenizer should still work, falling back to LIKE.
+func (db *SQLiteDatabase) InitSearchIndex(ctx context.Context) error {
+	var exists int
+	if err := db.GetContext(ctx, &exists, `SELECT COUNT(*) FROM sqlite_master WHERE name = 'bookmark_fts'`); err != nil {
+		return errors.WithStack(err)
+	}
+
+	err := db.withTx(ctx, func(tx *sqlx.Tx) error {
+		for _, stmt := range searchIndexSchema {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		// Index existing bookmarks when the table is new
+		if exists == 0 {
+			_, err := tx.ExecContext(ctx, `INSERT INTO bookmark_fts(rowid, title, url, excerpt, content)
+				SELECT b.id, b.title, b.url, b.excerpt, IFNULL(bc.content, '')
+				FROM bookmark b LEFT JOIN bookmark_content bc ON bc.docid = b.id`)
+			return errors.WithStack(err)
+		}
+		return nil
+	})
+
+	searchIndexEnabled = err == nil
+	return err
+}
+
 // SaveBookmarks saves new or updated bookmarks to database.
 // Returns the saved ID and error message if any happened.
 func (db *SQLiteDatabase) SaveBookmarks(ctx context.Context, create bool, bookmarks ...model2.BookmarkModel) ([]model2.BookmarkModel, error) {
@@ -82,15 +137,15 @@ func (db *SQLiteDatabase) SaveBookmarks(ctx context.Context, create bool, bookma
 		// Prepare statement
 
 		stmtInsertBook, err := tx.PreparexContext(ctx, `INSERT INTO bookmark
-			(url, title, excerpt, uid, public, modified )
-			VALUES(?, ?, ?, ?, ?, ?, ?) RETURNING id`)
+			(url, title, excerpt, uid, public, modified, created_at, modified_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
 		stmtUpdateBook, err := tx.PreparexContext(ctx, `UPDATE bookmark SET
 			url = ?, title = ?,	excerpt = ?, uid = ?,
-			public = ?, modified = ? 
+			public = ?, modified = ?, modified_at = ?
 			WHERE id = ?`)
 		if err != nil {
 			return errors.WithStack(err)
@@ -115,7 +170,7 @@ func (db *SQLiteDatabase) SaveBookmarks(ctx context.Context, create bool, bookma
 			return errors.WithStack(err)
 		}
 
-		stmtInsertTag, err := tx.PreparexContext(ctx, `INSERT INTO tag (name) VALUES (?)`)
+		stmtInsertTag, err := tx.PreparexContext(ctx, `INSERT INTO tag (name, parent_id) VALUES (?, ?)`)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -130,7 +185,7 @@ func (db *SQLiteDatabase) SaveBookmarks(ctx context.Context, create bool, bookma
 		}
 
 		// Prepare modified time
-		modifiedTime := itime.NewUnixNow().Format()
+		now := time.Now()
 
 		// Execute statements
 
@@ -144,19 +199,17 @@ func (db *SQLiteDatabase) SaveBookmarks(ctx context.Context, create bool, bookma
 				return errors.New("title must not be empty")
 			}
 
-			// Set modified time
-			if book.Modified == "" {
-				book.Modified = modifiedTime
-			}
+			// Set modified time, and created time for new bookmarks
+			book.SyncTimestamps(now)
 
 			// Create or update bookmark
 			var err error
 			if create {
 				err = stmtInsertBook.QueryRowContext(ctx,
-					book.URL, book.Title, book.Excerpt, book.Uid, book.Public, book.Modified).Scan(&book.ID)
+					book.URL, book.Title, book.Excerpt, book.Uid, book.Public, book.Modified, book.CreatedAt, book.ModifiedAt).Scan(&book.ID)
 			} else {
 				_, err = stmtUpdateBook.ExecContext(ctx,
-					book.URL, book.Title, book.Excerpt, book.Uid, book.Public, book.Modified, book.ID)
+					book.URL, book.Title, book.Excerpt, book.Uid, book.Public, book.Modified, book.ModifiedAt, book.ID)
 			}
 			if err != nil {
 				return errors.WithStack(err)
@@ -186,29 +239,40 @@ func (db *SQLiteDatabase) SaveBookmarks(ctx context.Context, create bool, bookma
 			for _, tag := range book.TagsDetail {
 				// If it's deleted tag, delete and continue
 				// Normalize tag name
-				tagName := strings.ToLower(tag.Name)
-				tagName = strings.Join(strings.Fields(tagName), " ")
+				tagName := NormalizeTagPath(strings.ToLower(tag.Name))
+				if len(tagName) == 0 {
+					continue
+				}
 
 				// If tag doesn't have any ID, fetch it from database
 				if tag.ID == 0 {
-					if err := stmtGetTag.GetContext(ctx, &tag.ID, tagName); err != nil && err != sql.ErrNoRows {
-						return errors.WithStack(err)
-					}
+					// Walk the path from the root, so missing parent tags are created as well
+					segments := strings.Split(tagName, "/")
+					for i := range segments {
+						path, parentID := strings.Join(segments[:i+1], "/"), tag.ID
+						tag.ID = 0
 
-					// If tag doesn't exist in database, save it
-					if tag.ID == 0 {
-						res, err := stmtInsertTag.ExecContext(ctx, tagName)
-						if err != nil {
+						if err := stmtGetTag.GetContext(ctx, &tag.ID, path); err != nil && err != sql.ErrNoRows {
 							return errors.WithStack(err)
 						}
 
-						tagID64, err := res.LastInsertId()
-						if err != nil && err != sql.ErrNoRows {
-							return errors.WithStack(err)
-						}
+						// If tag doesn't exist in database, save it
+						if tag.ID == 0 {
+							res, err := stmtInsertTag.ExecContext(ctx, path, parentID)
+							if err != nil {
+								return errors.WithStack(err)
+							}
+
+							tagID64, err := res.LastInsertId()
+							if err != nil && err != sql.ErrNoRows {
+								return errors.WithStack(err)
+							}
 
-						tag.ID = int(tagID64)
+							tag.ID = int(tagID64)
+						}
+						tag.ParentId = parentID
 					}
+					tag.Name = tagName
 
 					if _, err := stmtInsertBookTag.ExecContext(ctx, tag.ID, book.ID); err != nil {
 						return errors.WithStack(err)
@@ -241,7 +305,24 @@ func (db *SQLiteDatabase) GetBookmarks(ctx context.Context, opts GetBookmarksOpt
 		b.excerpt,
 		b.uid,
 		b.public,
-		b.modified
+		b.modified,
+		b.created_at,
+		b.modified_at,
+		b.visited_at,
+		b.author,
+		b.published,
+		b.site_name,
+		b.favicon,
+		b.content_type,
+		b.file_name,
+		b.file_size,
+		b.archive,
+		b.warc,
+		b.status,
+		b.favorite,
+		b.read_at,
+		b.archived_at,
+		b.favorited_at
 		FROM bookmark b
 		WHERE 1`
 
@@ -256,9 +337,12 @@ func (db *SQLiteDatabase) GetBookmarks(ctx context.Context, opts GetBookmarksOpt
 
 	// Add where clause for search keyword
 	if opts.Keyword != "" {
-		query += ` AND (b.url LIKE ? OR b.excerpt LIKE ? )`
+		query += ` AND (b.url LIKE ? OR b.excerpt LIKE ? OR ` + NoteKeywordClause + `)`
 
 		args = append(args,
+			"%"+opts.Keyword+"%",
+			"%"+opts.Keyword+"%",
+			"%"+opts.Keyword+"%",
 			"%"+opts.Keyword+"%",
 			"%"+opts.Keyword+"%")
 		// Replace dash with spaces since FTS5 uses `-name` as column identifier
@@ -266,71 +350,48 @@ func (db *SQLiteDatabase) GetBookmarks(ctx context.Context, opts GetBookmarksOpt
 		//args = append(args, opts.Keyword, opts.Keyword)
 	}
 
-	// Add where clause for tags.
-	// First we check for * in excluded and included tags,
-	// which means all tags will be excluded and included, respectively.
-	excludeAllTags := false
-	for _, excludedTag := range opts.ExcludedTags {
-		if excludedTag == "*" {
-			excludeAllTags = true
-			opts.ExcludedTags = []string{}
-			break
-		}
+	// Add where clause for search query
+	if clause, queryArgs := opts.Query.SQL(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, queryArgs...)
 	}
 
-	includeAllTags := false
-	for _, includedTag := range opts.Tags {
-		if includedTag == "*" {
-			includeAllTags = true
-			opts.Tags = []string{}
-			break
-		}
+	// Add where clause for domain, time range, screenshot, public and owner
+	if clause, filterArgs := opts.FieldFilters(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, filterArgs...)
 	}
 
-	// If all tags excluded, we will only show bookmark without tags.
-	// In other hand, if all tags included, we will only show bookmark with tags.
-	if excludeAllTags {
-		query += ` AND b.id NOT IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)`
-	} else if includeAllTags {
-		query += ` AND b.id IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)`
+	// Add where clause for reading state
+	if opts.Status != "" {
+		query += ` AND b.status = ?`
+		args = append(args, opts.Status)
 	}
 
-	// Now we only need to find the normal tags
-	if len(opts.Tags) > 0 {
-		query += ` AND b.id IN (
-			SELECT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(?)
-			GROUP BY bt.bookmark_id
-			HAVING COUNT(bt.bookmark_id) = ?)`
-
-		args = append(args, opts.Tags, len(opts.Tags))
+	if opts.Favorite {
+		query += ` AND b.favorite = 1`
 	}
 
-	if len(opts.ExcludedTags) > 0 {
-		query += ` AND b.id NOT IN (
-			SELECT DISTINCT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(?))`
+	// Add where clause for tags, a tag also matches its descendants
+	if clause, tagArgs := opts.TagFilters(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, tagArgs...)
+	}
 
-		args = append(args, opts.ExcludedTags)
+	// Add where clause for keyset pagination
+	if clause, cursorArgs := opts.CursorClause(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, cursorArgs...)
 	}
 
 	// Add order clause
-	switch opts.OrderMethod {
-	case ByLastAdded:
-		query += ` ORDER BY b.id DESC`
-	case ByLastModified:
-		query += ` ORDER BY b.modified DESC`
-	default:
-		query += ` ORDER BY b.id`
-	}
+	orderClause, orderArgs := opts.OrderClause()
+	query += ` ORDER BY ` + orderClause
+	args = append(args, orderArgs...)
 
 	if opts.Limit > 0 && opts.Offset >= 0 {
 		query += ` LIMIT ? OFFSET ?`
-		args = append(args, opts.Limit, opts.Offset)
+		args = append(args, opts.Limit, opts.Offset+opts.CursorOffset())
 	}
 
 	// Expand query, because some of the args might be an array
@@ -362,14 +423,14 @@ func (db *SQLiteDatabase) GetBookmarks(ctx context.Context, opts GetBookmarksOpt
 	tags := make([]tagContent, 0, len(bookmarks))
 	tagsMap := make(map[int][]model2.TagModel, len(bookmarks))
 
-	tagsQuery, tagArgs, err := sqlx.In(`SELECT bt.bookmark_id, t.id, t.name
+	tagsQuery, tagArgs, err := sqlx.In(`SELECT bt.bookmark_id, t.id, t.name, t.parent_id
 		FROM bookmark_tag bt
 		LEFT JOIN tag t ON bt.tag_id = t.id
 		WHERE bt.bookmark_id IN (?)
 		ORDER BY t.name`, bookmarkIds)
 	tagsQuery = db.Rebind(tagsQuery)
 	if err != nil {
-		log.Println(`SELECT bt.bookmark_id, t.id, t.name
+		log.Println(`SELECT bt.bookmark_id, t.id, t.name, t.parent_id
 		FROM bookmark_tag bt
 		LEFT JOIN tag t ON bt.tag_id = t.id
 		WHERE bt.bookmark_id IN (?)
@@ -421,64 +482,43 @@ func (db *SQLiteDatabase) GetBookmarksCount(ctx context.Context, opts GetBookmar
 
 	// Add where clause for search keyword
 	if opts.Keyword != "" {
-		query += ` AND (b.url LIKE ? OR b.excerpt LIKE ?)`
+		query += ` AND (b.url LIKE ? OR b.excerpt LIKE ? OR ` + NoteKeywordClause + `)`
 
 		args = append(args,
 			"%"+opts.Keyword+"%",
 			"%"+opts.Keyword+"%",
+			"%"+opts.Keyword+"%",
+			"%"+opts.Keyword+"%",
+			"%"+opts.Keyword+"%",
 		)
 	}
 
-	// Add where clause for tags.
-	// First we check for * in excluded and included tags,
-	// which means all tags will be excluded and included, respectively.
-	excludeAllTags := false
-	for _, excludedTag := range opts.ExcludedTags {
-		if excludedTag == "*" {
-			excludeAllTags = true
-			opts.ExcludedTags = []string{}
-			break
-		}
+	// Add where clause for search query
+	if clause, queryArgs := opts.Query.SQL(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, queryArgs...)
 	}
 
-	includeAllTags := false
-	for _, includedTag := range opts.Tags {
-		if includedTag == "*" {
-			includeAllTags = true
-			opts.Tags = []string{}
-			break
-		}
+	// Add where clause for domain, time range, screenshot, public and owner
+	if clause, filterArgs := opts.FieldFilters(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, filterArgs...)
 	}
 
-	// If all tags excluded, we will only show bookmark without tags.
-	// In other hand, if all tags included, we will only show bookmark with tags.
-	if excludeAllTags {
-		query += ` AND b.id NOT IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)`
-	} else if includeAllTags {
-		query += ` AND b.id IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)`
+	// Add where clause for reading state
+	if opts.Status != "" {
+		query += ` AND b.status = ?`
+		args = append(args, opts.Status)
 	}
 
-	// Now we only need to find the normal tags
-	if len(opts.Tags) > 0 {
-		query += ` AND b.id IN (
-			SELECT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(?)
-			GROUP BY bt.bookmark_id
-			HAVING COUNT(bt.bookmark_id) = ?)`
-
-		args = append(args, opts.Tags, len(opts.Tags))
+	if opts.Favorite {
+		query += ` AND b.favorite = 1`
 	}
 
-	if len(opts.ExcludedTags) > 0 {
-		query += ` AND b.id NOT IN (
-			SELECT DISTINCT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(?))`
-
-		args = append(args, opts.ExcludedTags)
+	// Add where clause for tags, a tag also matches its descendants
+	if clause, tagArgs := opts.TagFilters(); clause != "" {
+		query += ` AND ` + clause
+		args = append(args, tagArgs...)
 	}
 
 	// Expand query, because some of the args might be an array
@@ -713,7 +753,7 @@ func (db *SQLiteDatabase) CreateTags(ctx context.Context, tags ...model2.TagMode
 // GetTags fetch list of tags and their frequency.
 func (db *SQLiteDatabase) GetTags(ctx context.Context) ([]model2.TagModel, error) {
 	tags := []model2.TagModel{}
-	query := `SELECT bt.tag_id id, t.name 
+	query := `SELECT bt.tag_id id, t.name, t.parent_id
 		FROM bookmark_tag bt
 		LEFT JOIN tag t ON bt.tag_id = t.id
 		GROUP BY bt.tag_id ORDER BY t.name`
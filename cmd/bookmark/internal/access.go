@@ -0,0 +1,135 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/spf13/viper"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 签名地址的有效期, 可在配置 [image] sign_ttl 中修改
+const defaultImageSignTTL = time.Hour * 24
+
+var (
+	imageSignOnce   sync.Once
+	imageSignSecret []byte
+)
+
+// CanViewBookmark 书签所有者、公开书签以及管理员可以查看
+func CanViewBookmark(bookmark model2.BookmarkModel, uid int) bool {
+	if bookmark.ID <= 0 {
+		return false
+	}
+	if bookmark.Public == 1 || (uid > 0 && bookmark.Uid == uid) {
+		return true
+	}
+	return IsOwnerAccount(uid)
+}
+
+// IsOwnerAccount 是否为管理员账号
+func IsOwnerAccount(uid int) bool {
+	if uid <= 0 {
+		return false
+	}
+	orm, _ := igorm.GetGormSQLite("cache")
+	account := model2.AccountModel{}
+	orm.Where("id = ?", uid).First(&account)
+	return account.ID > 0 && account.Owner
+}
+
+// CanViewImage 截图属于调用者可以查看的书签或快照
+func (that bookmarksInternal) CanViewImage(name string, uid int) bool {
+	var ids []int
+	that.orm.Model(&model2.BookmarkModel{}).Where("image_url = ?", name).Pluck("id", &ids)
+
+	var snapshotIds []int
+	that.orm.Model(&model2.BookmarkSnapshotModel{}).Where("image_url = ?", name).Pluck("bookmark_id", &snapshotIds)
+
+	for _, id := range append(ids, snapshotIds...) {
+		if CanViewBookmark(that.InfoById(id), uid) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignedImageURL 书签截图的签名地址, 用于无法携带 Authorization 头的 <img> 标签.
+// 过期时间按有效期取整, 同一时间段内地址不变, 方便浏览器缓存; 截图更换后 v 参数随之变化.
+func SignedImageURL(bookmarkId, snapshotId int, imageURL string) string {
+	ttl := imageSignTTL()
+	window := int64(ttl / time.Second)
+	exp := (time.Now().Unix()/window + 2) * window
+
+	query := url.Values{}
+	if snapshotId > 0 {
+		query.Set("snapshot", strconv.Itoa(snapshotId))
+	}
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sig", imageSignature(bookmarkId, snapshotId, exp))
+	if version := strings.TrimSuffix(imageURL, filepath.Ext(imageURL)); len(version) > 0 {
+		if len(version) > 12 {
+			version = version[:12]
+		}
+		query.Set("v", version)
+	}
+	return fmt.Sprintf("/api/bookmarks/%d/image?%s", bookmarkId, query.Encode())
+}
+
+// VerifyImageSign 校验截图签名地址
+func VerifyImageSign(bookmarkId, snapshotId int, exp int64, sig string) bool {
+	if len(sig) == 0 || exp < time.Now().Unix() {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(imageSignature(bookmarkId, snapshotId, exp)))
+}
+
+// FillImageSrc 为调用者可以查看的书签生成截图签名地址
+func (that bookmarksInternal) FillImageSrc(list []model2.BookmarkModel, uid int) []model2.BookmarkModel {
+	isOwner := IsOwnerAccount(uid)
+	for i, bookmark := range list {
+		canView := isOwner || bookmark.Public == 1 || (uid > 0 && bookmark.Uid == uid)
+		if len(bookmark.ImageURL) > 0 && canView {
+			list[i].ImageSrc = SignedImageURL(bookmark.ID, 0, bookmark.ImageURL)
+		}
+	}
+	return list
+}
+
+func imageSignature(bookmarkId, snapshotId int, exp int64) string {
+	mac := hmac.New(sha256.New, imageSecret())
+	fmt.Fprintf(mac, "%d:%d:%d", bookmarkId, snapshotId, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// imageSecret 签名密钥, 未配置 [image] sign_secret 时每次启动随机生成, 重启后旧地址失效
+func imageSecret() []byte {
+	imageSignOnce.Do(func() {
+		if secret := viper.GetString("image.sign_secret"); len(secret) > 0 {
+			imageSignSecret = []byte(secret)
+			return
+		}
+		imageSignSecret = make([]byte, 32)
+		if _, err := rand.Read(imageSignSecret); err != nil {
+			log.Println("image sign secret", err)
+		}
+	})
+	return imageSignSecret
+}
+
+func imageSignTTL() time.Duration {
+	if ttl := viper.GetDuration("image.sign_ttl"); ttl >= time.Minute {
+		return ttl
+	}
+	return defaultImageSignTTL
+}
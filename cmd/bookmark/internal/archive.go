@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bookmark/cmd/bookmark/internal/consts"
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/archiver"
+	"bookmark/pkg/storage"
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"time"
+)
+
+// 生成存档的超时时间, 包括下载所有样式、图片和字体
+const archiveTimeout = time.Minute * 3
+
+// SaveArchive 生成网页的单文件存档, 保存到存储的 consts.ArchiveDir, 返回文件名
+func (that bookmarksInternal) SaveArchive(ctx context.Context, name string, pageURL string, html []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+
+	data, err := archiver.Archive(ctx, pageURL, html)
+	if err != nil {
+		return "", err
+	}
+
+	name += ".html"
+	if err := storage.PutBytes(ctx, storage.Default(), ArchiveKey(name), data, "text/html; charset=utf-8"); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// archivePage 后台生成网页存档, 开启 WARC 时保存抓取网页和存档资源的记录.
+// 存档文件关联到快照, 同时作为书签当前的存档.
+func (that bookmarksInternal) archivePage(ctx context.Context, id, snapshotId int, meta pageMeta, recorder *warcRecorder) {
+	name := fmt.Sprintf("%d-%d", id, snapshotId)
+	updates := map[string]interface{}{}
+
+	if len(meta.HTML) > 0 {
+		if archive, err := that.SaveArchive(ctx, name, meta.URL, meta.HTML); err != nil {
+			log.Println("save archive", meta.URL, err)
+		} else {
+			updates["archive"] = archive
+		}
+	}
+
+	if recorder != nil {
+		if warcName, err := recorder.save(name); err != nil {
+			log.Println("save warc", meta.URL, err)
+		} else {
+			updates["warc"] = warcName
+		}
+	}
+
+	if len(updates) == 0 {
+		return
+	}
+
+	old := that.InfoById(id)
+	that.orm.Model(&model2.BookmarkSnapshotModel{}).Where("id = ?", snapshotId).Updates(updates)
+	that.orm.Model(&model2.BookmarkModel{}).Where("id = ?", id).Updates(updates)
+
+	// 之前的存档没有被快照引用时删除
+	snapshotInternal := NewSnapshotsInternal()
+	snapshotInternal.RemoveUnusedArchive(old.Archive)
+	snapshotInternal.RemoveUnusedArchive(old.Warc)
+}
+
+// ArchiveKey 存档或 WARC 文件在存储中的路径
+func ArchiveKey(name string) string {
+	return path.Join(consts.ArchiveDir, path.Base(name))
+}
@@ -2,17 +2,16 @@ package internal
 
 import (
 	"bookmark/cmd/bookmark/database"
-	"bookmark/cmd/bookmark/internal/consts"
 	model2 "bookmark/cmd/bookmark/model"
 	"bookmark/pkg/db"
-	"fmt"
-	"github.com/PuerkitoBio/goquery"
+	"bookmark/pkg/fetch"
+	"context"
 	"github.com/cute-angelia/go-utils/components/igorm"
-	"github.com/guonaihong/gout"
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
-	"os"
-	"path/filepath"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -37,30 +36,147 @@ func (that bookmarksInternal) Info(uri string) model2.BookmarkModel {
 
 // GetBookmarkList 查询书签列表
 func (that bookmarksInternal) GetBookmarkList(opts database.GetBookmarksOptions, page, perpage int) (list []model2.BookmarkModel, count int64) {
-	ormSearch := that.orm
+	ormSearch := that.order(that.filter(opts), opts)
+
+	list, count, _ = db.Paginate[model2.BookmarkModel](ormSearch, page, perpage)
+
+	list = that.FillTagsDetail(list)
+	return
+}
+
+// GetBookmarkPage 按游标查询书签列表, 从 opts.After 之后开始, 最多 limit 条.
+// 还有更多书签时返回下一页的游标, withTotal 为 false 时不统计总数
+func (that bookmarksInternal) GetBookmarkPage(opts database.GetBookmarksOptions, limit int, withTotal bool) (list []model2.BookmarkModel, next *database.Cursor, total int64, err error) {
+	opts.OrderMethod = listOrder(opts.OrderMethod)
+	if opts.After != nil {
+		if listOrder(opts.After.Order) != opts.OrderMethod || opts.After.Reverse != opts.Reverse {
+			return nil, nil, 0, errors.New("cursor 与排序不一致")
+		}
+		opts.Seed = opts.After.Seed
+	}
+
+	ormSearch := that.filter(opts)
+	if clause, args := opts.CursorClause(); clause != "" {
+		ormSearch = ormSearch.Where(clause, args...)
+	}
+	offset := opts.CursorOffset()
+	that.order(ormSearch, opts).Offset(offset).Limit(limit + 1).Find(&list)
+
+	if len(list) > limit {
+		list = list[:limit]
+		cursor := database.NewCursor(opts, list[limit-1], offset+limit)
+		next = &cursor
+	}
+	if withTotal {
+		total = that.Count(opts)
+	}
+
+	list = that.FillTagsDetail(list)
+	return
+}
+
+// order 按 opts 排序, 默认从新到旧
+func (that bookmarksInternal) order(ormSearch *gorm.DB, opts database.GetBookmarksOptions) *gorm.DB {
+	opts.OrderMethod = listOrder(opts.OrderMethod)
+	orderClause, args := opts.OrderClause()
+	return ormSearch.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: orderClause, Vars: args, WithoutParentheses: true}})
+}
+
+// listOrder 列表默认从新到旧
+func listOrder(order database.OrderMethod) database.OrderMethod {
+	if order == database.DefaultOrder {
+		return database.ByLastAdded
+	}
+	return order
+}
+
+// Count 符合条件的书签数量
+func (that bookmarksInternal) Count(opts database.GetBookmarksOptions) (count int64) {
+	that.filter(opts).Model(&model2.BookmarkModel{}).Count(&count)
+	return
+}
+
+// StateCounts 各阅读状态的书签数量
+type StateCounts struct {
+	All      int64 `json:"all"`
+	Unread   int64 `json:"unread"`
+	Read     int64 `json:"read"`
+	Archived int64 `json:"archived"`
+	Favorite int64 `json:"favorite"`
+}
+
+// CountByState 按阅读状态统计书签数量, 使用关键字和标签条件, 不限制状态和收藏
+func (that bookmarksInternal) CountByState(opts database.GetBookmarksOptions) (counts StateCounts) {
+	opts.Status, opts.Favorite = "", false
+	return that.countByState(opts)
+}
+
+// countByState 按阅读状态统计符合全部条件的书签数量
+func (that bookmarksInternal) countByState(opts database.GetBookmarksOptions) (counts StateCounts) {
+	var rows []struct {
+		Status   string
+		Total    int64
+		Favorite int64
+	}
+	that.filter(opts).Model(&model2.BookmarkModel{}).
+		Select("status, count(*) as total, sum(favorite) as favorite").
+		Group("status").Scan(&rows)
+
+	for _, row := range rows {
+		counts.All += row.Total
+		counts.Favorite += row.Favorite
+		switch row.Status {
+		case model2.BookmarkStatusUnread:
+			counts.Unread = row.Total
+		case model2.BookmarkStatusRead:
+			counts.Read = row.Total
+		case model2.BookmarkStatusArchived:
+			counts.Archived = row.Total
+		}
+	}
+	return
+}
 
-	ormSearch = ormSearch.Order("id desc")
+// filter 书签查询条件
+func (that bookmarksInternal) filter(opts database.GetBookmarksOptions) *gorm.DB {
+	ormSearch := that.orm
 
 	// Add where clause for IDs
 	if len(opts.IDs) > 0 {
 		ormSearch = ormSearch.Or("id in (?)", opts.IDs)
 	}
 
+	// 关键字同时搜索笔记和高亮
 	if len(opts.Keyword) > 0 {
-		ormSearch = ormSearch.Where("title like ? ", "%"+opts.Keyword+"%")
+		keyword := "%" + opts.Keyword + "%"
+		ormSearch = ormSearch.Where("title like ? OR "+database.NoteKeywordClause, keyword, keyword, keyword, keyword)
 	}
 
-	if len(opts.Tags) > 0 {
-		tags := []model2.TagModel{}
-		that.orm.Where("name in (?)", opts.Tags).Find(&tags)
-		for _, tag := range tags {
-			ormSearch = ormSearch.Where("tags like ? ", fmt.Sprint("%"+fmt.Sprintf("%d", tag.ID)+"%"))
-		}
+	if clause, args := opts.Query.SQL(); clause != "" {
+		ormSearch = ormSearch.Where(clause, args...)
 	}
 
-	list, count, _ = db.Paginate[model2.BookmarkModel](ormSearch, page, perpage)
+	if clause, args := opts.FieldFilters(); clause != "" {
+		ormSearch = ormSearch.Where(clause, args...)
+	}
+
+	if len(opts.Status) > 0 {
+		ormSearch = ormSearch.Where("status = ?", opts.Status)
+	}
 
-	// 组装 tags_detail
+	if opts.Favorite {
+		ormSearch = ormSearch.Where("favorite = 1")
+	}
+
+	// 标签同时匹配下级标签, 默认需要全部满足
+	if clause, args := opts.TagFilters(); clause != "" {
+		ormSearch = ormSearch.Where(clause, args...)
+	}
+	return ormSearch
+}
+
+// FillTagsDetail 组装 tags_detail
+func (that bookmarksInternal) FillTagsDetail(list []model2.BookmarkModel) []model2.BookmarkModel {
 	for i, model := range list {
 		tagsarray := strings.Split(model.Tags, ",")
 		for _, tagIdstr := range tagsarray {
@@ -70,8 +186,7 @@ func (that bookmarksInternal) GetBookmarkList(opts database.GetBookmarksOptions,
 			}
 		}
 	}
-
-	return
+	return list
 }
 
 // GetTagInfo 获取tag信息
@@ -91,6 +206,26 @@ func (that bookmarksInternal) GetTagIdByBookMarkId(id int) (ids []int) {
 	return
 }
 
+// loadTags 读取书签的 tags 列, 由 bookmark_tag 的触发器维护
+func (that bookmarksInternal) loadTags(id int) string {
+	var tags []string
+	that.orm.Model(&model2.BookmarkModel{}).Where("id = ?", id).Pluck("tags", &tags)
+	if len(tags) == 0 {
+		return ""
+	}
+	return tags[0]
+}
+
+// GetUserBookmarks 获取用户的书签, ids 为空时返回全部
+func (that bookmarksInternal) GetUserBookmarks(uid int, ids []int) (list []model2.BookmarkModel) {
+	ormSearch := that.orm.Where("uid = ?", uid)
+	if len(ids) > 0 {
+		ormSearch = ormSearch.Where("id in (?)", ids)
+	}
+	ormSearch.Order("id asc").Find(&list)
+	return
+}
+
 func (that bookmarksInternal) InfoById(id int) model2.BookmarkModel {
 	orm, _ := igorm.GetGormSQLite("cache")
 	bookmark := model2.BookmarkModel{}
@@ -100,20 +235,53 @@ func (that bookmarksInternal) InfoById(id int) model2.BookmarkModel {
 
 func (that bookmarksInternal) Delete(bookmark model2.BookmarkModel) {
 	orm, _ := igorm.GetGormSQLite("cache")
+	// 删除快照
+	snapshotInternal := NewSnapshotsInternal()
+	snapshotInternal.DeleteByBookmark(bookmark.ID)
+	NewNotesInternal().DeleteByBookmark(bookmark.ID)
+
 	// 删除之前关系
 	orm.Table(model2.BookmarkTagModel{}.TableName()).Where("bookmark_id = ?", bookmark.ID).Delete(model2.BookmarkTagModel{})
+	orm.Table(model2.BookmarkContentModel{}.TableName()).Where("docid = ?", bookmark.ID).Delete(model2.BookmarkContentModel{})
 	orm.Table(model2.BookmarkModel{}.TableName()).Where("id = ?", bookmark.ID).Delete(model2.BookmarkModel{})
 
-	// 删除缩略图
-	os.Remove(filepath.Join(consts.UploadDir, bookmark.ImageURL))
+	// 删除缩略图和存档, 合并书签时保留的文件仍被引用, 不会删除
+	snapshotInternal.RemoveUnusedImage(bookmark.ImageURL)
+	snapshotInternal.RemoveUnusedArchive(bookmark.Archive)
+	snapshotInternal.RemoveUnusedArchive(bookmark.Warc)
+	markRelatedDirty(bookmark.ID)
+	emitBookmarkWebhook(model2.WebhookEventBookmarkDeleted, bookmark)
 }
 
 // CreateOrEdit 创建书签获取更新书签
 func (that bookmarksInternal) CreateOrEdit(bookmark model2.BookmarkModel, tags []string) model2.BookmarkModel {
 	orm, _ := igorm.GetGormSQLite("cache")
 
-	if len(bookmark.Title) == 0 {
-		bookmark = that.GetNetInfo(bookmark)
+	// 开启 WARC 时记录抓取网页和存档资源的请求
+	ctx := context.Background()
+	var recorder *warcRecorder
+	if WarcEnabled() {
+		recorder = newWarcRecorder()
+		ctx = fetch.WithRecorder(ctx, recorder.record)
+	}
+
+	// 更新前的截图
+	var old model2.BookmarkModel
+	if bookmark.ID > 0 {
+		old = that.InfoById(bookmark.ID)
+	}
+
+	// 新建书签或没有标题时抓取网页元数据, 补全为空的字段, 自动标签规则需要正文和内容类型;
+	// 更新已有标题的书签时不等待抓取, 保存后在后台重新抓取
+	recapture := bookmark.ID > 0 && len(bookmark.Title) > 0
+	var meta pageMeta
+	if !recapture {
+		bookmark, meta = that.GetNetInfo(ctx, bookmark)
+
+		// 没有截图时使用网页的预览图
+		if len(bookmark.ImageURL) == 0 {
+			bookmark.ImageURL = that.savePreview(bookmark.URL, meta)
+		}
 	}
 
 	// 判断是更新还是新建
@@ -123,66 +291,297 @@ func (that bookmarksInternal) CreateOrEdit(bookmark model2.BookmarkModel, tags [
 		_, tagIds := tagInternal.InsertTag(tags)
 		tagInternal.UpdateRelationship(bookmark.ID, tagIds)
 
-		var idStrings []string
-		for _, id := range tagIds {
-			idStrings = append(idStrings, strconv.Itoa(id))
-		}
-		bookmark.Tags = strings.Join(idStrings, ",")
-
-		// 更新书签内容
+		// 更新书签内容, 标题、摘要和公开状态影响相关书签
 		orm.Save(&bookmark)
+		markRelatedDirty(bookmark.ID)
+		bookmark.Tags = that.loadTags(bookmark.ID)
 
 		//go that.CatchShotPicture(bookmark)
 	} else {
+		// 自动标签规则
+		tags = NewTagRulesInternal().ApplyToNew(&bookmark, meta.Content, tags)
+
 		// 标签处理
 		tagInternal := NewTagInternal()
 		_, tagIds := tagInternal.InsertTag(tags)
 
 		// 新建书签
-		var idStrings []string
-		for _, id := range tagIds {
-			idStrings = append(idStrings, strconv.Itoa(id))
-		}
-		bookmark.Tags = strings.Join(idStrings, ",")
 		orm.Create(&bookmark)
 
 		// 更新标签关系
 		tagInternal.UpdateRelationship(bookmark.ID, tagIds)
+		bookmark.Tags = that.loadTags(bookmark.ID)
 
 		//go that.CatchShotPicture(bookmark)
 	}
 
+	if recapture {
+		go that.recapture(ctx, bookmark, old.ImageURL, recorder)
+	} else {
+		snapshot := that.capture(bookmark, old.ImageURL, meta)
+
+		// 生成网页存档, 需要下载样式和图片, 放到后台执行
+		if len(meta.HTML) > 0 || recorder != nil {
+			go that.archivePage(ctx, bookmark.ID, snapshot.ID, meta, recorder)
+		}
+	}
+
+	if old.ID > 0 {
+		emitBookmarkWebhook(model2.WebhookEventBookmarkUpdated, bookmark)
+	} else {
+		emitBookmarkWebhook(model2.WebhookEventBookmarkCreated, bookmark)
+	}
 	return bookmark
 }
 
+// capture 保存正文和快照, 按保留策略清理旧快照. oldImageURL 为更新前的截图
+func (that bookmarksInternal) capture(bookmark model2.BookmarkModel, oldImageURL string, meta pageMeta) model2.BookmarkSnapshotModel {
+	// 保存正文
+	if len(meta.Content) > 0 {
+		that.SaveContent(bookmark.ID, bookmark.Title, meta.Content, "")
+	}
+
+	// 保存快照, 按保留策略清理旧快照
+	snapshotInternal := NewSnapshotsInternal()
+	snapshot := snapshotInternal.Create(bookmark, meta.Content)
+	snapshotInternal.Prune(bookmark.ID)
+
+	// 截图更新后, 旧截图没有被快照引用时删除
+	if oldImageURL != bookmark.ImageURL {
+		snapshotInternal.RemoveUnusedImage(oldImageURL)
+	}
+	return snapshot
+}
+
+// recapture 在后台重新抓取已保存的书签, 补全为空的元数据, 保存快照和网页存档
+func (that bookmarksInternal) recapture(ctx context.Context, bookmark model2.BookmarkModel, oldImageURL string, recorder *warcRecorder) {
+	bookmark, meta := that.GetNetInfo(ctx, bookmark)
+	if len(bookmark.ImageURL) == 0 {
+		bookmark.ImageURL = that.savePreview(bookmark.URL, meta)
+	}
+
+	// 只更新抓取得到的字段, 标签、公开等这期间的修改不受影响
+	that.orm.Model(&model2.BookmarkModel{}).Where("id = ?", bookmark.ID).UpdateColumns(map[string]interface{}{
+		"title":        bookmark.Title,
+		"excerpt":      bookmark.Excerpt,
+		"author":       bookmark.Author,
+		"published":    bookmark.Published,
+		"site_name":    bookmark.SiteName,
+		"favicon":      bookmark.Favicon,
+		"content_type": bookmark.ContentType,
+		"file_name":    bookmark.FileName,
+		"file_size":    bookmark.FileSize,
+		"image_url":    bookmark.ImageURL,
+	})
+	markRelatedDirty(bookmark.ID)
+
+	snapshot := that.capture(bookmark, oldImageURL, meta)
+	if len(meta.HTML) > 0 || recorder != nil {
+		that.archivePage(ctx, bookmark.ID, snapshot.ID, meta, recorder)
+	}
+}
+
+// SaveContent 保存书签存档内容
+func (that bookmarksInternal) SaveContent(id int, title, content, html string) {
+	that.orm.Save(&model2.BookmarkContentModel{
+		DocId:   id,
+		Title:   title,
+		Content: content,
+		HTML:    html,
+	})
+	markRelatedDirty(id)
+}
+
 // 抓取缩略图
 func (that bookmarksInternal) CatchShotPicture(bookmark model2.BookmarkModel) {
 	// todo
 	log.Print("抓取缩略图")
 }
 
-func (that bookmarksInternal) GetNetInfo(bookmark model2.BookmarkModel) model2.BookmarkModel {
-	resp := that.getContent(bookmark.URL)
-	if q, err := goquery.NewDocumentFromReader(strings.NewReader(resp)); err != nil {
-		log.Println("goquery.new", err)
-	} else {
-		title := q.Find("title").Text()
-		if len(title) > 0 {
-			bookmark.Title = title
+// GetNetInfo 抓取网页元数据, 补全书签中为空的字段
+func (that bookmarksInternal) GetNetInfo(ctx context.Context, bookmark model2.BookmarkModel) (model2.BookmarkModel, pageMeta) {
+	resp := that.fetch(ctx, bookmark.URL)
+	if len(resp.Body) == 0 {
+		return bookmark, pageMeta{}
+	}
+
+	meta := parseResponse(resp, bookmark.URL)
+
+	if len(bookmark.Title) == 0 {
+		bookmark.Title = meta.Title
+	}
+	if len(bookmark.Excerpt) == 0 {
+		bookmark.Excerpt = meta.Excerpt
+	}
+	if len(bookmark.Author) == 0 {
+		bookmark.Author = meta.Author
+	}
+	if len(bookmark.Published) == 0 {
+		bookmark.Published = meta.Published
+	}
+	if len(bookmark.SiteName) == 0 {
+		bookmark.SiteName = meta.SiteName
+	}
+	if len(bookmark.Favicon) == 0 {
+		bookmark.Favicon = meta.Favicon
+	}
+	bookmark.ContentType = meta.ContentType
+	bookmark.FileName = meta.FileName
+	bookmark.FileSize = meta.FileSize
+	return bookmark, meta
+}
+
+// savePreview 内容本身是图片的直接作为预览图, 否则下载网页的预览图, 返回文件名
+func (that bookmarksInternal) savePreview(uri string, meta pageMeta) string {
+	if len(meta.ImageData) > 0 {
+		if imageURL, err := SaveImage(meta.ImageData); err != nil {
+			log.Println("save preview", uri, err)
+		} else {
+			return imageURL
 		}
 	}
-	return bookmark
+	if len(meta.ImageURL) > 0 {
+		if imageURL, err := that.DownloadImage(meta.ImageURL); err != nil {
+			log.Println("download preview", meta.ImageURL, err)
+		} else {
+			return imageURL
+		}
+	}
+	return ""
+}
+
+// RefetchPreview 重新抓取网页并下载预览图, 替换书签的截图
+func (that bookmarksInternal) RefetchPreview(bookmark model2.BookmarkModel) (string, error) {
+	_, meta := that.GetNetInfo(context.Background(), bookmark)
+	imageURL := that.savePreview(bookmark.URL, meta)
+	if len(imageURL) == 0 {
+		return "", errors.Errorf("no preview for %s", bookmark.URL)
+	}
+	that.orm.Model(&model2.BookmarkModel{}).Where("id = ?", bookmark.ID).Update("image_url", imageURL)
+	return imageURL, nil
+}
+
+// DownloadImage 下载图片保存到 consts.UploadDir, 返回文件名
+func (that bookmarksInternal) DownloadImage(uri string) (string, error) {
+	data := that.getBytes(uri)
+	if len(data) == 0 {
+		return "", errors.New("empty image")
+	}
+	return SaveImage(data)
+}
+
+func (that bookmarksInternal) getBytes(uri string) []byte {
+	return that.fetch(context.Background(), uri).Body
+}
+
+func (that bookmarksInternal) fetch(ctx context.Context, uri string) pageResponse {
+	resp, err := fetch.Get(ctx, uri)
+	if err != nil {
+		log.Println("fetch", uri, err)
+		return pageResponse{Header: http.Header{}}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Println("fetch", uri, resp.StatusCode)
+		return pageResponse{Header: resp.Header}
+	}
+	return pageResponse{URL: resp.URL, Body: resp.Body, Header: resp.Header}
+}
+
+// Visit 记录书签的打开时间, 只记录所有者打开自己的书签
+func (that bookmarksInternal) Visit(bookmark model2.BookmarkModel, uid int) {
+	if bookmark.ID <= 0 || bookmark.Uid != uid {
+		return
+	}
+	that.orm.Model(&model2.BookmarkModel{}).Where("id = ?", bookmark.ID).UpdateColumn("visited_at", time.Now().Unix())
 }
 
-func (that bookmarksInternal) getContent(uri string) string {
-	var resp string
-	if err := gout.GET(uri).SetHeader(gout.H{
-		"User-Agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36",
-	}).SetTimeout(time.Second * 10).BindBody(&resp).Do(); err != nil {
-		proxySocks5 := strings.Replace(os.Getenv("PROXYADDR"), "socks5://", "", -1)
-		gout.GET(uri).SetHeader(gout.H{
-			"User-Agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36",
-		}).SetSOCKS5(proxySocks5).SetTimeout(time.Second * 10).BindBody(&resp).Do()
+// SetState 批量修改阅读状态和收藏, status 为空时不修改状态, favorite 为 nil 时不修改收藏.
+// 只修改 uid 自己的书签, 管理员也不例外. 返回修改的书签数量
+func (that bookmarksInternal) SetState(uid int, ids []int, status string, favorite *bool) int64 {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	updates := map[string]interface{}{}
+
+	// 状态不变时保留原来的时间
+	switch status {
+	case model2.BookmarkStatusUnread:
+		updates["status"] = status
+		updates["read_at"] = ""
+		updates["archived_at"] = ""
+	case model2.BookmarkStatusRead:
+		updates["status"] = status
+		updates["read_at"] = gorm.Expr("CASE WHEN status = ? THEN read_at ELSE ? END", status, now)
+		updates["archived_at"] = ""
+	case model2.BookmarkStatusArchived:
+		updates["status"] = status
+		updates["read_at"] = gorm.Expr("CASE WHEN read_at = '' THEN ? ELSE read_at END", now)
+		updates["archived_at"] = gorm.Expr("CASE WHEN status = ? THEN archived_at ELSE ? END", status, now)
+	}
+
+	if favorite != nil {
+		if *favorite {
+			updates["favorite"] = 1
+			updates["favorited_at"] = gorm.Expr("CASE WHEN favorite = 1 THEN favorited_at ELSE ? END", now)
+		} else {
+			updates["favorite"] = 0
+			updates["favorited_at"] = ""
+		}
+	}
+
+	if len(ids) == 0 || len(updates) == 0 {
+		return 0
+	}
+
+	return that.orm.Model(&model2.BookmarkModel{}).Where("id in (?) AND uid = ?", ids, uid).Updates(updates).RowsAffected
+}
+
+// ValidStatus 是否为有效的阅读状态
+func ValidStatus(status string) bool {
+	switch status {
+	case model2.BookmarkStatusUnread, model2.BookmarkStatusRead, model2.BookmarkStatusArchived:
+		return true
+	}
+	return false
+}
+
+// SortOrder 排序: added 添加时间 (默认), modified 修改时间, title 标题, domain 域名, visited 打开时间,
+// relevance 关键字相关度, random 随机, read 阅读时间, archived 归档时间, favorited 收藏时间.
+// 可以加上 :asc 或 :desc 指定方向, 标题和域名默认从小到大, 其他默认从新到旧. reverse 为和默认相反的方向
+func SortOrder(sort string) (order database.OrderMethod, reverse bool, ok bool) {
+	key, dir, _ := strings.Cut(sort, ":")
+	asc := false
+	switch key {
+	case "", "added":
+		order = database.ByLastAdded
+	case "modified":
+		order = database.ByLastModified
+	case "title":
+		order, asc = database.ByTitle, true
+	case "domain":
+		order, asc = database.ByDomain, true
+	case "visited":
+		order = database.ByLastVisited
+	case "relevance":
+		order = database.ByRelevance
+	case "random":
+		order, asc = database.ByRandom, true
+	case "read":
+		order = database.ByLastRead
+	case "archived":
+		order = database.ByLastArchived
+	case "favorited":
+		order = database.ByLastFavorited
+	default:
+		return database.ByLastAdded, false, false
+	}
+
+	switch dir {
+	case "":
+	case "asc":
+		reverse = !asc
+	case "desc":
+		reverse = asc
+	default:
+		return order, false, false
 	}
-	return resp
+	return order, reverse, true
 }
@@ -1,5 +1,8 @@
 package consts
 
+// 文件在存储中的路径前缀, 本地存储时位于 [storage.local] dir 目录下
 const (
-	UploadDir = "data/uploads"
+	UploadDir  = "uploads"
+	ThumbDir   = "uploads/thumbs"
+	ArchiveDir = "archives"
 )
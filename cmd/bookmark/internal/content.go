@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	nurl "net/url"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// pageResponse 抓取结果
+type pageResponse struct {
+	URL    string // 跳转之后的最终地址
+	Body   []byte
+	Header http.Header
+}
+
+// mediaType 返回不含参数的 Content-Type, 服务器没有返回时根据内容猜测
+func (resp pageResponse) mediaType() string {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(resp.Body))
+	}
+	return strings.ToLower(mediaType)
+}
+
+// fileName 优先使用 Content-Disposition 中的文件名, 其次使用 url 路径
+func (resp pageResponse) fileName(uri string) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := params["filename"]; name != "" {
+			return name
+		}
+	}
+
+	if u, err := nurl.Parse(uri); err == nil {
+		if name := path.Base(u.Path); name != "/" && name != "." {
+			return name
+		}
+		return u.Hostname()
+	}
+	return uri
+}
+
+// parseResponse 根据内容类型解析抓取结果
+func parseResponse(resp pageResponse, uri string) pageMeta {
+	meta := pageMeta{}
+	mediaType := resp.mediaType()
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		body := toUTF8(resp.Body, resp.Header.Get("Content-Type"))
+		if q, err := goquery.NewDocumentFromReader(bytes.NewReader(body)); err != nil {
+			log.Println("goquery.new", err)
+		} else {
+			meta = parsePageMeta(q, uri)
+		}
+		meta.HTML = body
+
+	case mediaType == "application/pdf":
+		meta = parsePDF(resp.Body)
+		meta.FileName = resp.fileName(uri)
+		meta.FileSize = int64(len(resp.Body))
+
+	case strings.HasPrefix(mediaType, "image/"):
+		meta.ImageData = resp.Body
+		meta.FileName = resp.fileName(uri)
+		meta.FileSize = int64(len(resp.Body))
+
+	case strings.HasPrefix(mediaType, "text/"):
+		meta.Content = string(toUTF8(resp.Body, resp.Header.Get("Content-Type")))
+		meta.FileName = resp.fileName(uri)
+		meta.FileSize = int64(len(resp.Body))
+
+	default:
+		meta.FileName = resp.fileName(uri)
+		meta.FileSize = int64(len(resp.Body))
+	}
+
+	if meta.Title == "" {
+		meta.Title = meta.FileName
+	}
+	meta.ContentType = mediaType
+	meta.URL = firstNonEmpty(resp.URL, uri)
+	return meta
+}
+
+// parsePDF 提取 pdf 标题、作者和正文
+func parsePDF(data []byte) (meta pageMeta) {
+	// 解析库遇到损坏的文件会 panic
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println("parse pdf", err)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Println("parse pdf", err)
+		return
+	}
+
+	info := reader.Trailer().Key("Info")
+	meta.Title = strings.TrimSpace(info.Key("Title").Text())
+	meta.Author = strings.TrimSpace(info.Key("Author").Text())
+
+	if text, err := reader.GetPlainText(); err == nil {
+		content, _ := io.ReadAll(text)
+		meta.Content = strings.TrimSpace(string(content))
+	}
+	return
+}
+
+// 没有声明编码时依次尝试的中日韩编码
+var cjkEncodings = []encoding.Encoding{
+	simplifiedchinese.GB18030,
+	japanese.ShiftJIS,
+	japanese.EUCJP,
+	korean.EUCKR,
+	traditionalchinese.Big5,
+}
+
+// toUTF8 根据 BOM、Content-Type 和 meta 标签检测编码并转换为 utf-8
+func toUTF8(body []byte, contentType string) []byte {
+	e, name, certain := charset.DetermineEncoding(body, contentType)
+
+	// 没有找到编码声明且内容不是 utf-8 时, 默认是 windows-1252, 尝试中日韩编码
+	if !certain && name == "windows-1252" {
+		if guess := guessCJKEncoding(body); guess != nil {
+			e = guess
+		}
+	}
+
+	if result, err := e.NewDecoder().Bytes(body); err == nil {
+		return result
+	}
+	return body
+}
+
+// guessCJKEncoding 选择解码错误最少的编码, 非 ascii 字符中含有较多假名时优先日文编码
+func guessCJKEncoding(body []byte) encoding.Encoding {
+	var best encoding.Encoding
+	bestBad := -1
+
+	for _, e := range cjkEncodings {
+		text, err := e.NewDecoder().Bytes(body)
+		if err != nil {
+			continue
+		}
+
+		bad, kana, total := 0, 0, 0
+		for len(text) > 0 {
+			r, size := utf8.DecodeRune(text)
+			text = text[size:]
+			if r < utf8.RuneSelf {
+				continue
+			}
+			total++
+			if r == utf8.RuneError {
+				bad++
+			} else if unicode.In(r, unicode.Hiragana, unicode.Katakana) {
+				kana++
+			}
+		}
+
+		// 日文一般含有大量假名
+		if (e == japanese.ShiftJIS || e == japanese.EUCJP) && bad == 0 && kana*20 > total {
+			return e
+		}
+
+		if bestBad < 0 || bad < bestBad {
+			best, bestBad = e, bad
+		}
+	}
+
+	if bestBad != 0 {
+		return nil
+	}
+	return best
+}
@@ -0,0 +1,278 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/storage"
+	"bookmark/pkg/utils"
+	"context"
+	"fmt"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DuplicateByURL     = "url"     // 规范化后的 url 相同
+	DuplicateByTitle   = "title"   // 标题 + 域名相同
+	DuplicateByContent = "content" // 存档内容相似
+)
+
+// 内容相似判定: SimHash 海明距离阈值, 以及参与比较的最短内容
+const (
+	simHashMaxDistance = 3
+	simHashMinContent  = 200
+)
+
+// DuplicateGroup 一组重复书签
+type DuplicateGroup struct {
+	Reason    string                 `json:"reason"`
+	Key       string                 `json:"key"`
+	Bookmarks []model2.BookmarkModel `json:"bookmarks"`
+}
+
+type duplicatesInternal struct {
+	orm *gorm.DB
+}
+
+func NewDuplicatesInternal() *duplicatesInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &duplicatesInternal{
+		orm: orm,
+	}
+}
+
+type bookmarkWithContent struct {
+	model2.BookmarkModel
+	Content string `gorm:"column:content"`
+}
+
+// Report 查找用户的重复书签, 依次按规范化 url、标题 + 域名、内容相似度分组
+func (that duplicatesInternal) Report(uid int) []DuplicateGroup {
+	rows := []bookmarkWithContent{}
+	that.orm.Table("bookmark b").
+		Select("b.*, IFNULL(bc.content, '') content").
+		Joins("LEFT JOIN bookmark_content bc ON bc.docid = b.id").
+		Where("b.uid = ?", uid).
+		Order("b.id").
+		Scan(&rows)
+
+	groups := []DuplicateGroup{}
+	reported := map[string]bool{}
+
+	addGroup := func(reason, key string, members []int) {
+		if len(members) < 2 {
+			return
+		}
+		sort.Ints(members)
+
+		// 同一批书签只报告一次
+		var ids []string
+		for _, i := range members {
+			ids = append(ids, strconv.Itoa(rows[i].ID))
+		}
+		signature := strings.Join(ids, ",")
+		if reported[signature] {
+			return
+		}
+		reported[signature] = true
+
+		group := DuplicateGroup{Reason: reason, Key: key}
+		for _, i := range members {
+			group.Bookmarks = append(group.Bookmarks, rows[i].BookmarkModel)
+		}
+		group.Bookmarks = NewBookmarksInternal().FillTagsDetail(group.Bookmarks)
+		groups = append(groups, group)
+	}
+
+	// 1. 规范化 url
+	byURL := map[string][]int{}
+	var urlKeys []string
+	for i, row := range rows {
+		key, err := utils.NormalizeURL(row.URL)
+		if err != nil {
+			continue
+		}
+		if _, ok := byURL[key]; !ok {
+			urlKeys = append(urlKeys, key)
+		}
+		byURL[key] = append(byURL[key], i)
+	}
+	for _, key := range urlKeys {
+		addGroup(DuplicateByURL, key, byURL[key])
+	}
+
+	// 2. 标题 + 域名
+	byTitle := map[string][]int{}
+	var titleKeys []string
+	for i, row := range rows {
+		title := strings.ToLower(strings.Join(strings.Fields(row.Title), " "))
+		if title == "" {
+			continue
+		}
+		key := utils.GetDomain(row.URL) + " " + title
+		if _, ok := byTitle[key]; !ok {
+			titleKeys = append(titleKeys, key)
+		}
+		byTitle[key] = append(byTitle[key], i)
+	}
+	for _, key := range titleKeys {
+		addGroup(DuplicateByTitle, key, byTitle[key])
+	}
+
+	// 3. 存档内容相似度 (SimHash)
+	var candidates []int
+	fingerprints := map[int]uint64{}
+	for i, row := range rows {
+		if utf8.RuneCountInString(row.Content) < simHashMinContent {
+			continue
+		}
+		candidates = append(candidates, i)
+		fingerprints[i] = utils.SimHash(row.Content)
+	}
+
+	parent := map[int]int{}
+	var find func(i int) int
+	find = func(i int) int {
+		if p, ok := parent[i]; ok && p != i {
+			parent[i] = find(p)
+			return parent[i]
+		}
+		return i
+	}
+	for x := 0; x < len(candidates); x++ {
+		for y := x + 1; y < len(candidates); y++ {
+			a, b := candidates[x], candidates[y]
+			if utils.HammingDistance(fingerprints[a], fingerprints[b]) <= simHashMaxDistance {
+				parent[find(b)] = find(a)
+			}
+		}
+	}
+
+	byContent := map[int][]int{}
+	var roots []int
+	for _, i := range candidates {
+		root := find(i)
+		if _, ok := byContent[root]; !ok {
+			roots = append(roots, root)
+		}
+		byContent[root] = append(byContent[root], i)
+	}
+	for _, root := range roots {
+		addGroup(DuplicateByContent, fmt.Sprintf("%016x", fingerprints[root]), byContent[root])
+	}
+
+	return groups
+}
+
+// Merge 合并重复书签: 合并标签, 保留最早的修改时间和最佳截图, 删除其余书签
+func (that duplicatesInternal) Merge(keep model2.BookmarkModel, others []model2.BookmarkModel) model2.BookmarkModel {
+	all := append([]model2.BookmarkModel{keep}, others...)
+	bookmarkInternal := NewBookmarksInternal()
+
+	// 合并标签
+	var tagIds []int
+	seen := map[int]bool{}
+	for _, bookmark := range all {
+		for _, id := range bookmarkInternal.GetTagIdByBookMarkId(bookmark.ID) {
+			if !seen[id] {
+				seen[id] = true
+				tagIds = append(tagIds, id)
+			}
+		}
+	}
+	NewTagInternal().UpdateRelationship(keep.ID, tagIds)
+	keep.Tags = bookmarkInternal.loadTags(keep.ID)
+
+	// 保留最早的修改时间和添加时间
+	for _, bookmark := range others {
+		if bookmark.Modified != "" && (keep.Modified == "" || bookmark.Modified < keep.Modified) {
+			keep.Modified = bookmark.Modified
+		}
+		if bookmark.CreatedAt > 0 && (keep.CreatedAt <= 0 || bookmark.CreatedAt < keep.CreatedAt) {
+			keep.CreatedAt = bookmark.CreatedAt
+		}
+	}
+
+	// 保留最佳截图
+	oldImage := keep.ImageURL
+	keep.ImageURL = that.bestScreenshot(all)
+
+	// 保留最完整的存档内容
+	that.mergeContent(keep, others)
+
+	that.orm.Save(&keep)
+
+	// 其余书签的快照、笔记和高亮归到保留的书签
+	var otherIds []int
+	for _, bookmark := range others {
+		otherIds = append(otherIds, bookmark.ID)
+	}
+	that.orm.Model(&model2.BookmarkSnapshotModel{}).Where("bookmark_id in (?)", otherIds).Update("bookmark_id", keep.ID)
+	NewNotesInternal().MoveToBookmark(otherIds, keep.ID)
+
+	// 删除其余书签, 仍被引用的截图和存档不会删除
+	for _, bookmark := range others {
+		bookmarkInternal.Delete(bookmark)
+	}
+
+	snapshotInternal := NewSnapshotsInternal()
+	snapshotInternal.RemoveUnusedImage(oldImage)
+	snapshotInternal.Prune(keep.ID)
+	emitBookmarkWebhook(model2.WebhookEventBookmarkUpdated, keep)
+
+	keep.TagsDetail = nil
+	return bookmarkInternal.FillTagsDetail([]model2.BookmarkModel{keep})[0]
+}
+
+// bestScreenshot 选择分辨率最高的截图, 分辨率相同时选择文件较大的
+func (that duplicatesInternal) bestScreenshot(bookmarks []model2.BookmarkModel) string {
+	best := ""
+	bestArea, bestSize := -1, int64(-1)
+
+	for _, bookmark := range bookmarks {
+		if bookmark.ImageURL == "" {
+			continue
+		}
+
+		key := ImageKey(bookmark.ImageURL)
+		stat, err := storage.Default().Stat(context.Background(), key)
+		if err != nil {
+			continue
+		}
+
+		area := 0
+		if config, err := decodeImageConfig(context.Background(), key); err == nil {
+			area = config.Width * config.Height
+		}
+
+		if area > bestArea || (area == bestArea && stat.Size > bestSize) {
+			best, bestArea, bestSize = bookmark.ImageURL, area, stat.Size
+		}
+	}
+
+	return best
+}
+
+// mergeContent 当保留书签的存档内容比其他书签短时, 使用最长的存档内容
+func (that duplicatesInternal) mergeContent(keep model2.BookmarkModel, others []model2.BookmarkModel) {
+	current := model2.BookmarkContentModel{}
+	that.orm.Where("docid = ?", keep.ID).First(&current)
+
+	longest := current
+	for _, bookmark := range others {
+		content := model2.BookmarkContentModel{}
+		that.orm.Where("docid = ?", bookmark.ID).First(&content)
+		if len(content.Content) > len(longest.Content) {
+			longest = content
+		}
+	}
+
+	if len(longest.Content) > len(current.Content) {
+		longest.DocId = keep.ID
+		that.orm.Save(&longest)
+		markRelatedDirty(keep.ID)
+	}
+}
@@ -10,4 +10,7 @@ const (
 	ErrorBookmarkBase64Error     Code = 2002 // 解码base64字符串获取图片数据错误
 	ErrorBookmarkBase64WriteFile Code = 2003 // 将图片数据写入文件
 	ErrorBookmarkBase64Decode    Code = 2004 // 编码图片信息
+	ErrorBookmarkImageTooLarge   Code = 2005 // 截图文件过大
+	ErrorBookmarkImageDimension  Code = 2006 // 截图尺寸过大
+	ErrorBookmarkQuerySyntax     Code = 2007 // 搜索语句错误
 )
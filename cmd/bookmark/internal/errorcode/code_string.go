@@ -14,16 +14,19 @@ func _() {
 	_ = x[ErrorBookmarkBase64Error-2002]
 	_ = x[ErrorBookmarkBase64WriteFile-2003]
 	_ = x[ErrorBookmarkBase64Decode-2004]
+	_ = x[ErrorBookmarkImageTooLarge-2005]
+	_ = x[ErrorBookmarkImageDimension-2006]
+	_ = x[ErrorBookmarkQuerySyntax-2007]
 }
 
 const (
 	_Code_name_0 = "用户注册失败登录失败"
-	_Code_name_1 = "书签截图为空解码base64字符串获取图片数据错误将图片数据写入文件编码图片信息"
+	_Code_name_1 = "书签截图为空解码base64字符串获取图片数据错误将图片数据写入文件编码图片信息截图文件过大截图尺寸过大搜索语句错误"
 )
 
 var (
 	_Code_index_0 = [...]uint8{0, 18, 30}
-	_Code_index_1 = [...]uint8{0, 18, 63, 90, 108}
+	_Code_index_1 = [...]uint8{0, 18, 63, 90, 108, 126, 144, 162}
 )
 
 func (i Code) String() string {
@@ -31,7 +34,7 @@ func (i Code) String() string {
 	case 1000 <= i && i <= 1001:
 		i -= 1000
 		return _Code_name_0[_Code_index_0[i]:_Code_index_0[i+1]]
-	case 2001 <= i && i <= 2004:
+	case 2001 <= i && i <= 2007:
 		i -= 2001
 		return _Code_name_1[_Code_index_1[i]:_Code_index_1[i+1]]
 	default:
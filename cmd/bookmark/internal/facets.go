@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"bookmark/cmd/bookmark/database"
+	model2 "bookmark/cmd/bookmark/model"
+)
+
+// FacetLimit 标签和域名最多返回的数量
+const FacetLimit = 10
+
+// FacetCount 分组及其书签数量
+type FacetCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// Facets 搜索结果的分组统计, 用于继续筛选
+type Facets struct {
+	Tags    []FacetCount `json:"tags"`    // 书签最多的标签
+	Domains []FacetCount `json:"domains"` // 书签最多的域名, 去掉 www.
+	Months  []FacetCount `json:"months"`  // 每月添加的书签数量, 例如 2024-01, 从近到远
+	States  StateCounts  `json:"states"`  // 阅读状态
+}
+
+// Facets 统计符合条件的全部书签 (不只是当前页), limit 为标签和域名的数量
+func (that bookmarksInternal) Facets(opts database.GetBookmarksOptions, limit int) (facets Facets) {
+	if limit <= 0 {
+		limit = FacetLimit
+	}
+	ids := that.filter(opts).Model(&model2.BookmarkModel{}).Select("id")
+
+	facets.Tags = []FacetCount{}
+	that.orm.Raw(`SELECT t.name AS name, COUNT(*) AS count FROM bookmark_tag bt JOIN tag t ON bt.tag_id = t.id
+		WHERE bt.bookmark_id IN (?) GROUP BY t.id ORDER BY count DESC, t.name LIMIT ?`, ids, limit).Scan(&facets.Tags)
+
+	facets.Domains = []FacetCount{}
+	that.orm.Raw(`SELECT CASE WHEN substr(host, 1, 4) = 'www.' THEN substr(host, 5) ELSE host END AS name, COUNT(*) AS count
+		FROM (SELECT `+database.HostExpr+` AS host FROM bookmark WHERE id IN (?))
+		WHERE host <> '' GROUP BY name ORDER BY count DESC, name LIMIT ?`, ids, limit).Scan(&facets.Domains)
+
+	facets.Months = []FacetCount{}
+	that.orm.Raw(`SELECT strftime('%Y-%m', created_at, 'unixepoch', 'localtime') AS name, COUNT(*) AS count
+		FROM bookmark WHERE id IN (?) AND created_at > 0 GROUP BY name ORDER BY name DESC`, ids).Scan(&facets.Months)
+
+	facets.States = that.countByState(opts)
+	return
+}
@@ -0,0 +1,299 @@
+package internal
+
+import (
+	"bookmark/cmd/bookmark/internal/consts"
+	"bookmark/cmd/bookmark/internal/errorcode"
+	"bookmark/pkg/storage"
+	"bookmark/pkg/utils"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/cute-angelia/go-utils/utils/http/apiV2"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+)
+
+// 截图限制默认值, 可在配置 [image] 中修改
+const (
+	defaultImageMaxSize      = 10 << 20   // 文件大小
+	defaultImageMaxDimension = 10000      // 宽或高的像素数
+	defaultImageMaxPixels    = 40_000_000 // 总像素数, 解码后每像素占 4 字节
+)
+
+// 缩略图宽度, 请求的宽度向上取整到这些尺寸, 避免生成过多缓存
+var thumbnailWidths = []int{160, 320, 640, 1280}
+
+const thumbnailQuality = 85
+
+// 生成缩略图需要解码整张截图, 同一时间最多生成两张, 避免占用过多内存
+var imageDecodeSlots = make(chan struct{}, 2)
+
+// 同一个缩略图同一时间只生成一次, 已有缓存时不加锁
+var variantLocks = struct {
+	sync.Mutex
+	m map[string]*variantLock
+}{m: map[string]*variantLock{}}
+
+type variantLock struct {
+	sync.Mutex
+	refs int
+}
+
+// ImageFile 图片文件及响应头信息
+type ImageFile struct {
+	Key         string // 存储中的路径
+	Size        int64
+	ContentType string
+	ETag        string
+}
+
+// SaveImage 校验图片大小和尺寸, 按内容的 sha256 命名保存原图到存储, 返回文件名
+func SaveImage(imgData []byte) (string, error) {
+	maxSize, maxDimension, maxPixels := imageLimits()
+	if len(imgData) > maxSize {
+		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkImageTooLarge), errorcode.ErrorBookmarkImageTooLarge.String())
+	}
+
+	// 先只读取尺寸, 防止解码尺寸过大的图片
+	config, format, err := image.DecodeConfig(bytes.NewReader(imgData))
+	if err != nil {
+		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64Decode), errorcode.ErrorBookmarkBase64Decode.String()+" > "+err.Error())
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxDimension || config.Height > maxDimension ||
+		int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkImageDimension), fmt.Sprintf("%s > %dx%d", errorcode.ErrorBookmarkImageDimension.String(), config.Width, config.Height))
+	}
+
+	// 确认图片可以完整解码
+	if _, _, err := image.Decode(bytes.NewReader(imgData)); err != nil {
+		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64Decode), errorcode.ErrorBookmarkBase64Decode.String()+" > "+err.Error())
+	}
+
+	// 构造文件名, 相同内容只保存一份
+	sum := sha256.Sum256(imgData)
+	ext := format
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+	fileName := hex.EncodeToString(sum[:]) + "." + ext
+	key := ImageKey(fileName)
+
+	ctx := context.Background()
+	if storage.Exists(ctx, storage.Default(), key) {
+		return fileName, nil
+	}
+	if err := storage.PutBytes(ctx, storage.Default(), key, imgData, imageContentType(fileName)); err != nil {
+		return "", apiV2.NewApiError(int(errorcode.ErrorBookmarkBase64WriteFile), errorcode.ErrorBookmarkBase64WriteFile.String()+" > "+err.Error())
+	}
+
+	return fileName, nil
+}
+
+// ImageKey 截图在存储中的路径
+func ImageKey(name string) string {
+	return path.Join(consts.UploadDir, path.Base(name))
+}
+
+// ImageVariant 返回截图指定宽度的缩略图, 客户端支持时返回 webp.
+// 缩略图在首次请求时生成, 缓存在 consts.ThumbDir.
+func ImageVariant(name string, width int, acceptWebp bool) (ImageFile, error) {
+	name = path.Base(name)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	ctx := context.Background()
+	store := storage.Default()
+
+	base := ImageFile{
+		Key:         ImageKey(name),
+		ContentType: imageContentType(name),
+		ETag:        `"` + stem + `"`,
+	}
+	baseInfo, err := store.Stat(ctx, base.Key)
+	if err != nil {
+		return ImageFile{}, errors.Wrapf(err, "image %s", name)
+	}
+	base.Size = baseInfo.Size
+
+	// 原图比请求的宽度大时使用缩略图
+	if width = thumbnailWidth(width); width > 0 {
+		config, err := decodeImageConfig(ctx, base.Key)
+		if err != nil {
+			return ImageFile{}, err
+		}
+
+		if config.Width > width {
+			thumb := ImageFile{
+				Key:         path.Join(consts.ThumbDir, fmt.Sprintf("%s-%d.jpg", stem, width)),
+				ContentType: "image/jpeg",
+				ETag:        fmt.Sprintf(`"%s-%d"`, stem, width),
+			}
+			thumb.Size, err = generateVariant(ctx, thumb, base.Key, func(img image.Image) ([]byte, error) {
+				var buf bytes.Buffer
+				err := jpeg.Encode(&buf, utils.ResizeWidth(img, width), &jpeg.Options{Quality: thumbnailQuality})
+				return buf.Bytes(), err
+			})
+			if err != nil {
+				return ImageFile{}, err
+			}
+			base = thumb
+		}
+	}
+
+	// gif 原图可能是动图, 不转换
+	if !acceptWebp || base.ContentType == "image/gif" {
+		return base, nil
+	}
+
+	webp := ImageFile{
+		Key:         path.Join(consts.ThumbDir, strings.TrimSuffix(path.Base(base.Key), path.Ext(base.Key))+".webp"),
+		ContentType: "image/webp",
+		ETag:        strings.TrimSuffix(base.ETag, `"`) + `-webp"`,
+	}
+	webp.Size, err = generateVariant(ctx, webp, base.Key, func(img image.Image) ([]byte, error) {
+		var buf bytes.Buffer
+		err := nativewebp.Encode(&buf, img, nil)
+		return buf.Bytes(), err
+	})
+	if err != nil {
+		return ImageFile{}, err
+	}
+
+	// webp 为无损压缩, 比原格式大时仍使用原格式
+	if webp.Size < base.Size {
+		return webp, nil
+	}
+	return base, nil
+}
+
+// RemoveImageVariants 删除截图的缩略图缓存
+func RemoveImageVariants(name string) {
+	name = path.Base(name)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	ctx := context.Background()
+	store := storage.Default()
+
+	variants, err := store.List(ctx, path.Join(consts.ThumbDir, stem))
+	if err != nil {
+		log.Println("list image variants", name, err)
+		return
+	}
+	for _, variant := range variants {
+		variantName := path.Base(variant.Key)
+		if strings.HasPrefix(variantName, stem+"-") || variantName == stem+".webp" {
+			store.Delete(ctx, variant.Key)
+		}
+	}
+}
+
+// generateVariant 缓存文件不存在时解码 src 并生成, 返回文件大小
+func generateVariant(ctx context.Context, variant ImageFile, src string, encode func(img image.Image) ([]byte, error)) (int64, error) {
+	store := storage.Default()
+	if info, err := store.Stat(ctx, variant.Key); err == nil {
+		return info.Size, nil
+	}
+
+	// 等待锁期间其他请求可能已经生成
+	unlock := lockVariant(variant.Key)
+	defer unlock()
+	if info, err := store.Stat(ctx, variant.Key); err == nil {
+		return info.Size, nil
+	}
+
+	imageDecodeSlots <- struct{}{}
+	defer func() { <-imageDecodeSlots }()
+
+	body, _, err := store.Get(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	img, _, err := image.Decode(body)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	data, err := encode(img)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return int64(len(data)), storage.PutBytes(ctx, store, variant.Key, data, variant.ContentType)
+}
+
+// lockVariant 锁定缩略图的缓存路径, 返回解锁函数
+func lockVariant(key string) func() {
+	variantLocks.Lock()
+	lock, ok := variantLocks.m[key]
+	if !ok {
+		lock = &variantLock{}
+		variantLocks.m[key] = lock
+	}
+	lock.refs++
+	variantLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		variantLocks.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(variantLocks.m, key)
+		}
+		variantLocks.Unlock()
+	}
+}
+
+func decodeImageConfig(ctx context.Context, key string) (image.Config, error) {
+	body, _, err := storage.Default().Get(ctx, key)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer body.Close()
+
+	config, _, err := image.DecodeConfig(body)
+	return config, errors.WithStack(err)
+}
+
+// thumbnailWidth 向上取整到支持的缩略图宽度, 超过最大宽度时使用原图
+func thumbnailWidth(width int) int {
+	if width <= 0 {
+		return 0
+	}
+	for _, w := range thumbnailWidths {
+		if width <= w {
+			return w
+		}
+	}
+	return 0
+}
+
+func imageContentType(name string) string {
+	if contentType := mime.TypeByExtension(strings.ToLower(path.Ext(name))); strings.HasPrefix(contentType, "image/") {
+		return contentType
+	}
+	return "image/jpeg"
+}
+
+func imageLimits() (maxSize, maxDimension, maxPixels int) {
+	maxSize, maxDimension, maxPixels = defaultImageMaxSize, defaultImageMaxDimension, defaultImageMaxPixels
+	if v := viper.GetInt("image.max_size"); v > 0 {
+		maxSize = v
+	}
+	if v := viper.GetInt("image.max_dimension"); v > 0 {
+		maxDimension = v
+	}
+	if v := viper.GetInt("image.max_pixels"); v > 0 {
+		maxPixels = v
+	}
+	return
+}
@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"bookmark/cmd/bookmark/internal/consts"
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/storage"
+	"context"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"gorm.io/gorm"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// 最近修改的文件可能属于正在保存的书签 (截图先于书签保存, 存档在后台生成), 不作为孤儿文件
+const orphanGracePeriod = time.Hour
+
+// 每次检查最多重新下载的预览图数量, 避免请求耗时过长
+const maxRefetchPreviews = 50
+
+// 引用文件的类型
+const (
+	FileKindImage   = "image"
+	FileKindArchive = "archive"
+	FileKindWarc    = "warc"
+)
+
+// StorageFile 存储中的文件
+type StorageFile struct {
+	Key     string `json:"key"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"modTime"`
+}
+
+// MissingFile 书签或快照引用, 但存储中不存在的文件
+type MissingFile struct {
+	BookmarkId int    `json:"bookmarkId"`
+	SnapshotId int    `json:"snapshotId"` // 为 0 时是书签本身引用的文件
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Refetched  string `json:"refetched,omitempty"` // 重新下载的预览图
+}
+
+// StorageReport 存储检查结果
+type StorageReport struct {
+	Files       int           `json:"files"`
+	TotalSize   int64         `json:"totalSize"`
+	Missing     []MissingFile `json:"missing"`
+	Orphans     []StorageFile `json:"orphans"`
+	OrphanSize  int64         `json:"orphanSize"`
+	Deleted     int           `json:"deleted"`
+	DeletedSize int64         `json:"deletedSize"`
+	Refetched   int           `json:"refetched"`
+}
+
+type maintenanceInternal struct {
+	orm *gorm.DB
+}
+
+func NewMaintenanceInternal() *maintenanceInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &maintenanceInternal{
+		orm: orm,
+	}
+}
+
+// fileRef 书签或快照引用的文件
+type fileRef struct {
+	key  string
+	file MissingFile
+}
+
+// CheckStorage 对比存储中的文件和书签、快照引用的文件, 找出缺失的文件和没有被引用的孤儿文件.
+// deleteOrphans 删除孤儿文件, refetch 为缺少截图的书签重新下载网页预览图.
+func (that maintenanceInternal) CheckStorage(deleteOrphans, refetch bool) (StorageReport, error) {
+	ctx := context.Background()
+	store := storage.Default()
+	report := StorageReport{Missing: []MissingFile{}, Orphans: []StorageFile{}}
+
+	var objects []storage.ObjectInfo
+	for _, prefix := range []string{consts.UploadDir + "/", consts.ArchiveDir + "/"} {
+		list, err := store.List(ctx, prefix)
+		if err != nil {
+			return report, err
+		}
+		objects = append(objects, list...)
+	}
+
+	exists := map[string]bool{}
+	for _, object := range objects {
+		exists[object.Key] = true
+		report.Files++
+		report.TotalSize += object.Size
+	}
+
+	// 引用的文件, 以及存在的截图, 用于判断缩略图是否还有用
+	refs := that.fileRefs()
+	referenced := map[string]bool{}
+	imageStems := map[string]bool{}
+	for _, ref := range refs {
+		referenced[ref.key] = true
+		if !exists[ref.key] {
+			report.Missing = append(report.Missing, ref.file)
+		} else if ref.file.Kind == FileKindImage {
+			imageStems[strings.TrimSuffix(path.Base(ref.key), path.Ext(ref.key))] = true
+		}
+	}
+
+	deadline := time.Now().Add(-orphanGracePeriod)
+	for _, object := range objects {
+		if referenced[object.Key] || object.ModTime.After(deadline) {
+			continue
+		}
+		if strings.HasPrefix(object.Key, consts.ThumbDir+"/") && thumbnailUsed(object.Key, imageStems) {
+			continue
+		}
+
+		report.Orphans = append(report.Orphans, StorageFile{
+			Key:     object.Key,
+			Size:    object.Size,
+			ModTime: object.ModTime.Format("2006-01-02 15:04:05"),
+		})
+		report.OrphanSize += object.Size
+
+		if deleteOrphans {
+			if err := store.Delete(ctx, object.Key); err != nil {
+				log.Println("delete orphan", object.Key, err)
+				continue
+			}
+			report.Deleted++
+			report.DeletedSize += object.Size
+		}
+	}
+
+	if refetch {
+		that.refetchPreviews(&report)
+	}
+	return report, nil
+}
+
+// refetchPreviews 为截图文件缺失的书签重新下载预览图
+func (that maintenanceInternal) refetchPreviews(report *StorageReport) {
+	bookmarkInternal := NewBookmarksInternal()
+	snapshotInternal := NewSnapshotsInternal()
+
+	for i, missing := range report.Missing {
+		if report.Refetched >= maxRefetchPreviews {
+			return
+		}
+		if missing.Kind != FileKindImage || missing.SnapshotId > 0 {
+			continue
+		}
+
+		bookmark := bookmarkInternal.InfoById(missing.BookmarkId)
+		imageURL, err := bookmarkInternal.RefetchPreview(bookmark)
+		if err != nil {
+			log.Println("refetch preview", bookmark.ID, err)
+			continue
+		}
+		report.Missing[i].Refetched = imageURL
+		report.Refetched++
+
+		// 缺失的截图可能仍被快照引用, 没有引用时清理残留的缩略图
+		snapshotInternal.RemoveUnusedImage(missing.Name)
+	}
+}
+
+// fileRefs 书签和快照引用的全部文件
+func (that maintenanceInternal) fileRefs() (refs []fileRef) {
+	var bookmarks []model2.BookmarkModel
+	that.orm.Select("id, image_url, archive, warc").Find(&bookmarks)
+	for _, bookmark := range bookmarks {
+		refs = appendFileRefs(refs, bookmark.ID, 0, bookmark.ImageURL, bookmark.Archive, bookmark.Warc)
+	}
+
+	var snapshots []model2.BookmarkSnapshotModel
+	that.orm.Select("id, bookmark_id, image_url, archive, warc").Find(&snapshots)
+	for _, snapshot := range snapshots {
+		refs = appendFileRefs(refs, snapshot.BookmarkId, snapshot.ID, snapshot.ImageURL, snapshot.Archive, snapshot.Warc)
+	}
+	return
+}
+
+func appendFileRefs(refs []fileRef, bookmarkId, snapshotId int, image, archive, warc string) []fileRef {
+	for _, file := range []MissingFile{
+		{Kind: FileKindImage, Name: image},
+		{Kind: FileKindArchive, Name: archive},
+		{Kind: FileKindWarc, Name: warc},
+	} {
+		if len(file.Name) == 0 {
+			continue
+		}
+		file.BookmarkId, file.SnapshotId = bookmarkId, snapshotId
+
+		key := ArchiveKey(file.Name)
+		if file.Kind == FileKindImage {
+			key = ImageKey(file.Name)
+		}
+		refs = append(refs, fileRef{key: key, file: file})
+	}
+	return refs
+}
+
+// thumbnailUsed 缩略图对应的截图是否存在, 缩略图命名为 <stem>-<width>.jpg、<stem>-<width>.webp 或 <stem>.webp
+func thumbnailUsed(key string, imageStems map[string]bool) bool {
+	name := path.Base(key)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	if imageStems[stem] {
+		return true
+	}
+	i := strings.LastIndex(stem, "-")
+	return i > 0 && strings.Trim(stem[i+1:], "0123456789") == "" && imageStems[stem[:i]]
+}
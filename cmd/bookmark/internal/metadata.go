@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	nurl "net/url"
+	"strings"
+)
+
+// pageMeta 网页元数据, 来自 OpenGraph、Twitter card、meta 标签和 JSON-LD
+type pageMeta struct {
+	Title     string
+	Excerpt   string
+	ImageURL  string
+	Author    string
+	Published string
+	SiteName  string
+	Favicon   string
+	Content   string // 正文纯文本
+
+	ContentType string
+	FileName    string // 非网页内容的文件名
+	FileSize    int64  // 非网页内容的大小
+	ImageData   []byte // 内容本身是图片时的数据
+
+	URL  string // 跳转之后的最终地址
+	HTML []byte // 转换为 utf-8 的网页源码, 用于生成存档
+}
+
+// JSON-LD 中关注的类型
+var jsonLDTypes = map[string]bool{
+	"Article":          true,
+	"NewsArticle":      true,
+	"BlogPosting":      true,
+	"TechArticle":      true,
+	"ScholarlyArticle": true,
+	"Product":          true,
+	"VideoObject":      true,
+}
+
+// parsePageMeta 解析网页元数据, 优先级: OpenGraph > Twitter card > JSON-LD > 普通 meta 标签
+func parsePageMeta(doc *goquery.Document, pageURL string) pageMeta {
+	meta := pageMeta{}
+	ld := parseJSONLD(doc)
+
+	meta.Title = firstNonEmpty(
+		metaContent(doc, "og:title"),
+		metaContent(doc, "twitter:title"),
+		ld.Title,
+		doc.Find("title").First().Text(),
+	)
+	meta.Excerpt = firstNonEmpty(
+		metaContent(doc, "og:description"),
+		metaContent(doc, "twitter:description"),
+		ld.Excerpt,
+		metaContent(doc, "description"),
+	)
+	meta.ImageURL = firstNonEmpty(
+		metaContent(doc, "og:image:secure_url"),
+		metaContent(doc, "og:image"),
+		metaContent(doc, "og:image:url"),
+		metaContent(doc, "twitter:image"),
+		metaContent(doc, "twitter:image:src"),
+		ld.ImageURL,
+	)
+	meta.Author = firstNonEmpty(
+		metaContent(doc, "author"),
+		metaContent(doc, "article:author"),
+		ld.Author,
+		metaContent(doc, "twitter:creator"),
+	)
+	meta.Published = firstNonEmpty(
+		metaContent(doc, "article:published_time"),
+		ld.Published,
+		itempropContent(doc, "datePublished"),
+		metaContent(doc, "pubdate"),
+	)
+	meta.SiteName = firstNonEmpty(
+		metaContent(doc, "og:site_name"),
+		ld.SiteName,
+		metaContent(doc, "application-name"),
+	)
+
+	// favicon
+	doc.Find("link[rel]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+			if rel == "icon" {
+				meta.Favicon = s.AttrOr("href", "")
+				return false
+			}
+		}
+		return true
+	})
+	if meta.Favicon == "" {
+		meta.Favicon = "/favicon.ico"
+	}
+
+	meta.ImageURL = resolveURL(pageURL, meta.ImageURL)
+	meta.Favicon = resolveURL(pageURL, meta.Favicon)
+	meta.Content = readableText(doc)
+
+	return meta
+}
+
+// metaContent 按 property 或 name 读取 meta 标签
+func metaContent(doc *goquery.Document, key string) string {
+	content := ""
+	doc.Find("meta").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		property := s.AttrOr("property", s.AttrOr("name", ""))
+		if strings.EqualFold(property, key) {
+			content = strings.TrimSpace(s.AttrOr("content", ""))
+			return content == ""
+		}
+		return true
+	})
+	return content
+}
+
+func itempropContent(doc *goquery.Document, prop string) string {
+	s := doc.Find(`[itemprop="` + prop + `"]`).First()
+	return strings.TrimSpace(s.AttrOr("content", s.AttrOr("datetime", s.Text())))
+}
+
+// parseJSONLD 读取第一个受支持类型的 JSON-LD 对象
+func parseJSONLD(doc *goquery.Document) pageMeta {
+	meta := pageMeta{}
+
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+
+		for _, obj := range flattenJSONLD(data) {
+			if !isJSONLDType(obj["@type"]) {
+				continue
+			}
+
+			meta.Title = firstNonEmpty(jsonLDString(obj["headline"]), jsonLDString(obj["name"]))
+			meta.Excerpt = jsonLDString(obj["description"])
+			meta.ImageURL = firstNonEmpty(jsonLDString(obj["image"]), jsonLDString(obj["thumbnailUrl"]))
+			meta.Author = firstNonEmpty(jsonLDString(obj["author"]), jsonLDString(obj["brand"]))
+			meta.Published = firstNonEmpty(jsonLDString(obj["datePublished"]), jsonLDString(obj["uploadDate"]))
+			meta.SiteName = jsonLDString(obj["publisher"])
+			return false
+		}
+		return true
+	})
+
+	return meta
+}
+
+// flattenJSONLD 展开数组和 @graph
+func flattenJSONLD(data interface{}) []map[string]interface{} {
+	var result []map[string]interface{}
+
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			result = append(result, flattenJSONLD(item)...)
+		}
+	case map[string]interface{}:
+		result = append(result, v)
+		if graph, ok := v["@graph"]; ok {
+			result = append(result, flattenJSONLD(graph)...)
+		}
+	}
+
+	return result
+}
+
+func isJSONLDType(t interface{}) bool {
+	switch v := t.(type) {
+	case string:
+		return jsonLDTypes[v]
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && jsonLDTypes[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonLDString 读取字符串值, 对象取 name / url, 数组取第一个
+func jsonLDString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case []interface{}:
+		for _, item := range value {
+			if s := jsonLDString(item); s != "" {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		return firstNonEmpty(jsonLDString(value["name"]), jsonLDString(value["url"]))
+	}
+	return ""
+}
+
+// readableText 提取正文纯文本
+func readableText(doc *goquery.Document) string {
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript, iframe, svg, nav, header, footer, form").Remove()
+
+	root := body
+	if article := body.Find("article").First(); article.Length() > 0 {
+		root = article
+	} else if main := body.Find("main").First(); main.Length() > 0 {
+		root = main
+	}
+
+	// 块级元素之后换行
+	root.Find("p, div, li, h1, h2, h3, h4, h5, h6, pre, blockquote, tr, section, br").AfterHtml("\n")
+
+	var lines []string
+	for _, line := range strings.Split(root.Text(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func resolveURL(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	baseURL, err := nurl.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := nurl.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
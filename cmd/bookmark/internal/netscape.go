@@ -0,0 +1,123 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/netscape"
+	"bookmark/pkg/utils"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// ImportBookmarks 导入浏览器书签文件, 文件夹路径作为标签路径 (书签栏/Go 导入为标签 书签栏/Go), 同时保留 TAGS 属性中的标签.
+// 已存在的书签合并标签, 不重新抓取网页. public 为没有标记 PRIVATE 的书签是否公开
+func ImportBookmarks(uid int, items []netscape.Bookmark, public bool) (result ImportResult) {
+	bookmarkInternal := NewBookmarksInternal()
+	tagInternal := NewTagInternal()
+	ruleInternal := NewTagRulesInternal()
+
+	for _, item := range items {
+		uri, err := utils.RemoveUTMParams(item.URL)
+		if err != nil || !strings.HasPrefix(uri, "http") {
+			result.Skipped++
+			continue
+		}
+
+		tags := append([]string{}, item.Tags...)
+		if len(item.Folder) > 0 {
+			tags = append(tags, strings.Join(item.Folder, "/"))
+		}
+
+		bookmark := bookmarkInternal.Info(uri)
+		event := model2.WebhookEventBookmarkCreated
+		if bookmark.ID > 0 {
+			if bookmark.Uid != uid {
+				result.Skipped++
+				continue
+			}
+			for _, tag := range tagInternal.GetTags(bookmark.Tags) {
+				if tag.ID > 0 {
+					tags = append(tags, tag.Name)
+				}
+			}
+			if len(bookmark.Title) == 0 {
+				bookmark.Title = item.Title
+			}
+			if len(bookmark.Excerpt) == 0 {
+				bookmark.Excerpt = item.Description
+			}
+			bookmarkInternal.orm.Save(&bookmark)
+			event = model2.WebhookEventBookmarkUpdated
+			result.Updated++
+		} else {
+			bookmark = model2.BookmarkModel{
+				URL:     uri,
+				Title:   item.Title,
+				Excerpt: item.Description,
+				Uid:     uid,
+			}
+			if !item.AddDate.IsZero() {
+				bookmark.CreatedAt = item.AddDate.Unix()
+				bookmark.Modified = item.AddDate.Format(model2.TimeLayout)
+			}
+			if !item.LastModified.IsZero() {
+				bookmark.Modified = item.LastModified.Format(model2.TimeLayout)
+			}
+			if public && !item.Private {
+				bookmark.Public = 1
+			}
+			// 导入不抓取网页, 规则只匹配链接、标题和描述
+			tags = ruleInternal.ApplyToNew(&bookmark, "", tags)
+			bookmarkInternal.orm.Create(&bookmark)
+			result.Created++
+		}
+
+		_, tagIds := tagInternal.InsertTag(tags)
+		tagInternal.UpdateRelationship(bookmark.ID, tagIds)
+		bookmark.Tags = bookmarkInternal.loadTags(bookmark.ID)
+		emitBookmarkWebhook(event, bookmark)
+	}
+	return
+}
+
+// ExportNetscape 导出为浏览器书签文件, 书签放在第一个标签路径对应的文件夹中, 全部标签写入 TAGS 属性
+func ExportNetscape(w io.Writer, bookmarks []model2.BookmarkModel) error {
+	bookmarks = NewBookmarksInternal().FillTagsDetail(bookmarks)
+
+	items := make([]netscape.Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		item := netscape.Bookmark{
+			URL:         bookmark.URL,
+			Title:       bookmark.Title,
+			Description: bookmark.Excerpt,
+			Private:     bookmark.Public == 0,
+		}
+		if bookmark.CreatedAt > 0 {
+			item.AddDate = time.Unix(bookmark.CreatedAt, 0)
+		}
+		if bookmark.ModifiedAt > 0 {
+			item.LastModified = time.Unix(bookmark.ModifiedAt, 0)
+		}
+
+		for _, tag := range bookmark.TagsDetail {
+			if len(tag.Name) > 0 {
+				item.Tags = append(item.Tags, tag.Name)
+			}
+		}
+		sort.Strings(item.Tags)
+		if len(item.Tags) > 0 {
+			item.Folder = strings.Split(item.Tags[0], "/")
+		}
+		items = append(items, item)
+	}
+
+	return netscape.Write(w, "Bookmarks", items)
+}
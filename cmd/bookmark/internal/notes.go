@@ -0,0 +1,276 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"encoding/json"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type notesInternal struct {
+	orm *gorm.DB
+}
+
+func NewNotesInternal() *notesInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &notesInternal{
+		orm: orm,
+	}
+}
+
+// CanEditBookmark 书签所有者和管理员可以修改
+func CanEditBookmark(bookmark model2.BookmarkModel, uid int) bool {
+	if bookmark.ID <= 0 || uid <= 0 {
+		return false
+	}
+	return bookmark.Uid == uid || IsOwnerAccount(uid)
+}
+
+// Notes 书签的笔记, 按创建顺序
+func (that notesInternal) Notes(bookmarkId int) (list []model2.BookmarkNoteModel) {
+	list = []model2.BookmarkNoteModel{}
+	that.orm.Where("bookmark_id = ?", bookmarkId).Order("id asc").Find(&list)
+	return
+}
+
+// NoteInfo 笔记详情
+func (that notesInternal) NoteInfo(id int) (note model2.BookmarkNoteModel) {
+	that.orm.Where("id = ?", id).First(&note)
+	return
+}
+
+// SaveNote 新建或修改笔记
+func (that notesInternal) SaveNote(note model2.BookmarkNoteModel) model2.BookmarkNoteModel {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	note.Modified = now
+	if note.ID > 0 {
+		that.orm.Model(&model2.BookmarkNoteModel{}).Where("id = ?", note.ID).Updates(map[string]interface{}{
+			"content":  note.Content,
+			"modified": now,
+		})
+		return that.NoteInfo(note.ID)
+	}
+
+	note.Created = now
+	that.orm.Create(&note)
+	return note
+}
+
+// DeleteNote 删除笔记
+func (that notesInternal) DeleteNote(id int) {
+	that.orm.Where("id = ?", id).Delete(&model2.BookmarkNoteModel{})
+}
+
+// Highlights 书签的高亮, 按在正文中的位置排序
+func (that notesInternal) Highlights(bookmarkId int) (list []model2.BookmarkHighlightModel) {
+	list = []model2.BookmarkHighlightModel{}
+	that.orm.Where("bookmark_id = ?", bookmarkId).Order("snapshot_id asc, position_start asc, id asc").Find(&list)
+	return
+}
+
+// HighlightInfo 高亮详情
+func (that notesInternal) HighlightInfo(id int) (highlight model2.BookmarkHighlightModel) {
+	that.orm.Where("id = ?", id).First(&highlight)
+	return
+}
+
+// CreateHighlight 在快照正文中定位引用文本后保存高亮, 快照为 0 时使用最新的快照.
+// 快照没有正文时 (例如只有截图) 按提交的选择器保存.
+func (that notesInternal) CreateHighlight(highlight model2.BookmarkHighlightModel) (model2.BookmarkHighlightModel, error) {
+	if len(strings.TrimSpace(highlight.Exact)) == 0 {
+		return highlight, errors.New("高亮文本不能为空")
+	}
+
+	snapshotInternal := NewSnapshotsInternal()
+	if highlight.SnapshotId <= 0 {
+		if list := snapshotInternal.List(highlight.BookmarkId); len(list) > 0 {
+			highlight.SnapshotId = list[0].ID
+		}
+	}
+
+	content := ""
+	if highlight.SnapshotId > 0 {
+		snapshot := snapshotInternal.Info(highlight.SnapshotId)
+		if snapshot.BookmarkId != highlight.BookmarkId {
+			return highlight, errors.New("快照不存在")
+		}
+		content = snapshot.Content
+	}
+
+	if len(content) > 0 {
+		start, end, ok := AnchorQuote(content, highlight.Exact, highlight.Prefix, highlight.Suffix, highlight.Start)
+		if !ok {
+			return highlight, errors.New("高亮文本不在存档正文中")
+		}
+		highlight.Start, highlight.End = start, end
+		highlight.Prefix, highlight.Suffix = quoteContext(content, start, end)
+	} else if highlight.Start < 0 || highlight.End < highlight.Start {
+		highlight.Start, highlight.End = -1, -1
+	}
+
+	highlight.Created = time.Now().Format("2006-01-02 15:04:05")
+	that.orm.Create(&highlight)
+	return highlight, nil
+}
+
+// UpdateHighlight 修改高亮的颜色和批注, 不修改定位
+func (that notesInternal) UpdateHighlight(id int, color, note string) model2.BookmarkHighlightModel {
+	that.orm.Model(&model2.BookmarkHighlightModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"color": color,
+		"note":  note,
+	})
+	return that.HighlightInfo(id)
+}
+
+// DeleteHighlight 删除高亮
+func (that notesInternal) DeleteHighlight(id int) {
+	that.orm.Where("id = ?", id).Delete(&model2.BookmarkHighlightModel{})
+}
+
+// DeleteByBookmark 删除书签的全部笔记和高亮
+func (that notesInternal) DeleteByBookmark(bookmarkId int) {
+	that.orm.Where("bookmark_id = ?", bookmarkId).Delete(&model2.BookmarkNoteModel{})
+	that.orm.Where("bookmark_id = ?", bookmarkId).Delete(&model2.BookmarkHighlightModel{})
+}
+
+// MoveToBookmark 合并书签时, 笔记和高亮归到保留的书签
+func (that notesInternal) MoveToBookmark(ids []int, bookmarkId int) {
+	if len(ids) == 0 {
+		return
+	}
+	that.orm.Model(&model2.BookmarkNoteModel{}).Where("bookmark_id in (?)", ids).Update("bookmark_id", bookmarkId)
+	that.orm.Model(&model2.BookmarkHighlightModel{}).Where("bookmark_id in (?)", ids).Update("bookmark_id", bookmarkId)
+}
+
+// Annotations 书签的笔记和高亮
+type Annotations struct {
+	Notes      []model2.BookmarkNoteModel      `json:"notes"`
+	Highlights []model2.BookmarkHighlightModel `json:"highlights"`
+}
+
+// Export 导出书签的笔记和高亮
+func (that notesInternal) Export(bookmarkId int) Annotations {
+	return Annotations{
+		Notes:      that.Notes(bookmarkId),
+		Highlights: that.Highlights(bookmarkId),
+	}
+}
+
+// ExportedBookmark 导出的书签, 包括笔记和高亮
+type ExportedBookmark struct {
+	model2.BookmarkModel
+	Annotations
+}
+
+// ExportJSON 导出书签、标签、笔记和高亮
+func ExportJSON(w io.Writer, bookmarks []model2.BookmarkModel) error {
+	notesInternal := NewNotesInternal()
+	bookmarks = NewBookmarksInternal().FillTagsDetail(bookmarks)
+
+	list := make([]ExportedBookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		list = append(list, ExportedBookmark{
+			BookmarkModel: bookmark,
+			Annotations:   notesInternal.Export(bookmark.ID),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.WithStack(encoder.Encode(list))
+}
+
+// 高亮保存的前后文长度
+const quoteContextLength = 32
+
+// AnchorQuote 在正文中定位引用文本, 返回字符 (rune) 位置 [start, end).
+// hint 位置的文本一致时直接使用, 否则在所有出现的位置中选择前后文最匹配的, 相同时选择离 hint 最近的.
+func AnchorQuote(content, exact, prefix, suffix string, hint int) (start, end int, ok bool) {
+	if len(exact) == 0 {
+		return 0, 0, false
+	}
+	length := utf8.RuneCountInString(exact)
+
+	if hint >= 0 {
+		if i := runeOffset(content, hint); i >= 0 && strings.HasPrefix(content[i:], exact) {
+			return hint, hint + length, true
+		}
+	}
+
+	bestScore, bestDistance := -1, 0
+	offset, runes := 0, 0
+	for {
+		i := strings.Index(content[offset:], exact)
+		if i < 0 {
+			break
+		}
+		runes += utf8.RuneCountInString(content[offset : offset+i])
+		offset += i
+
+		score := commonSuffix(content[:offset], prefix) + commonPrefix(content[offset+len(exact):], suffix)
+		distance := runes - hint
+		if distance < 0 {
+			distance = -distance
+		}
+		if score > bestScore || (score == bestScore && hint >= 0 && distance < bestDistance) {
+			bestScore, bestDistance = score, distance
+			start, end, ok = runes, runes+length, true
+		}
+
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		offset += size
+		runes++
+	}
+	return
+}
+
+// quoteContext 引用文本前后的文字, 用于正文变化后重新定位
+func quoteContext(content string, start, end int) (prefix, suffix string) {
+	runes := []rune(content)
+	from := start - quoteContextLength
+	if from < 0 {
+		from = 0
+	}
+	to := end + quoteContextLength
+	if to > len(runes) {
+		to = len(runes)
+	}
+	return string(runes[from:start]), string(runes[end:to])
+}
+
+// runeOffset 第 n 个字符的字节位置, 超出范围时返回 -1
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	if n == 0 {
+		return len(s)
+	}
+	return -1
+}
+
+// commonPrefix 相同前缀的字节数
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// commonSuffix 相同后缀的字节数
+func commonSuffix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,315 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/utils"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+	"sync"
+)
+
+// RelatedLimit 书签详情返回的相关书签数量
+const RelatedLimit = 5
+
+// 相关书签的权重: 共同标签、同域名、标题摘要和存档正文的 TF-IDF 相似度
+const (
+	relatedTagWeight    = 0.4
+	relatedDomainWeight = 0.2
+	relatedTextWeight   = 0.4
+
+	relatedMinScore   = 0.05
+	relatedQueryTerms = 50    // 只用权重最高的词查询倒排索引
+	relatedMaxContent = 20000 // 参与计算的正文最多字数
+)
+
+// 相关的原因
+const (
+	RelatedByTag    = "tag"
+	RelatedByDomain = "domain"
+	RelatedByText   = "text"
+)
+
+// RelatedBookmark 相关书签, score 在 0 到 1 之间
+type RelatedBookmark struct {
+	model2.BookmarkModel
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// relatedDoc 倒排索引中的书签
+type relatedDoc struct {
+	uid     int
+	public  int
+	domain  string
+	tags    map[int]bool
+	terms   map[string]float64 // 词频
+	norm    float64
+	normGen int
+}
+
+// relatedIndex 进程内的倒排索引, 第一次查询时建立, 之后只重新索引改变的书签
+type relatedIndex struct {
+	mu       sync.Mutex
+	built    bool
+	gen      int // 索引改变后 idf 随之改变, 书签的向量长度需要重新计算
+	docs     map[int]*relatedDoc
+	postings map[string]map[int]float64
+	dirty    map[int]bool
+}
+
+var related = &relatedIndex{dirty: map[int]bool{}}
+
+// markRelatedDirty 书签的标签、内容或公开状态改变, 或书签删除后调用, 下次查询时重新索引
+func markRelatedDirty(ids ...int) {
+	related.mu.Lock()
+	defer related.mu.Unlock()
+	if !related.built {
+		return
+	}
+	for _, id := range ids {
+		related.dirty[id] = true
+	}
+}
+
+// Related 与书签相关的书签, 只返回 uid 可以查看的书签
+func (that bookmarksInternal) Related(bookmark model2.BookmarkModel, uid int, limit int) []RelatedBookmark {
+	if limit <= 0 {
+		limit = RelatedLimit
+	}
+	owner := IsOwnerAccount(uid)
+
+	related.mu.Lock()
+	related.refresh(that.orm)
+	scores := related.score(bookmark.ID, func(doc *relatedDoc) bool {
+		return doc.public == 1 || (uid > 0 && doc.uid == uid) || owner
+	})
+	related.mu.Unlock()
+
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	if len(scores) == 0 {
+		return []RelatedBookmark{}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for _, score := range scores {
+		ids = append(ids, score.ID)
+	}
+	bookmarks := []model2.BookmarkModel{}
+	that.orm.Where("id in (?)", ids).Find(&bookmarks)
+	byID := map[int]model2.BookmarkModel{}
+	for _, bookmark := range that.FillImageSrc(that.FillTagsDetail(bookmarks), uid) {
+		byID[bookmark.ID] = bookmark
+	}
+
+	list := []RelatedBookmark{}
+	for _, score := range scores {
+		if bookmark, ok := byID[score.ID]; ok && CanViewBookmark(bookmark, uid) {
+			score.BookmarkModel = bookmark
+			list = append(list, score)
+		}
+	}
+	return list
+}
+
+// refresh 建立索引或重新索引改变的书签, 调用时需要持有锁
+func (that *relatedIndex) refresh(orm *gorm.DB) {
+	if !that.built {
+		that.docs = map[int]*relatedDoc{}
+		that.postings = map[string]map[int]float64{}
+		that.load(orm, nil)
+		that.built, that.dirty = true, map[int]bool{}
+		that.gen++
+		return
+	}
+	if len(that.dirty) == 0 {
+		return
+	}
+
+	ids := make([]int, 0, len(that.dirty))
+	for id := range that.dirty {
+		that.remove(id)
+		ids = append(ids, id)
+	}
+	that.load(orm, ids)
+	that.dirty = map[int]bool{}
+	that.gen++
+}
+
+// load 读取书签加入索引, ids 为 nil 时读取全部书签
+func (that *relatedIndex) load(orm *gorm.DB, ids []int) {
+	var rows []struct {
+		ID      int
+		Uid     int
+		Public  int
+		URL     string
+		Title   string
+		Excerpt string
+		Content string
+	}
+	query := orm.Table("bookmark b").
+		Select("b.id, b.uid, b.public, b.url, b.title, b.excerpt, IFNULL(substr(bc.content, 1, ?), '') content", relatedMaxContent).
+		Joins("LEFT JOIN bookmark_content bc ON bc.docid = b.id")
+	if ids != nil {
+		query = query.Where("b.id in (?)", ids)
+	}
+	query.Scan(&rows)
+
+	var tags []model2.BookmarkTagModel
+	tagQuery := orm.Model(&model2.BookmarkTagModel{})
+	if ids != nil {
+		tagQuery = tagQuery.Where("bookmark_id in (?)", ids)
+	}
+	tagQuery.Find(&tags)
+	tagsByBookmark := map[int]map[int]bool{}
+	for _, tag := range tags {
+		if tagsByBookmark[tag.BookmarkId] == nil {
+			tagsByBookmark[tag.BookmarkId] = map[int]bool{}
+		}
+		tagsByBookmark[tag.BookmarkId][tag.TagId] = true
+	}
+
+	for _, row := range rows {
+		doc := &relatedDoc{
+			uid:    row.Uid,
+			public: row.Public,
+			domain: utils.GetDomain(row.URL),
+			tags:   tagsByBookmark[row.ID],
+			terms:  termFrequency(utils.Tokenize(row.Title + " " + row.Excerpt + " " + row.Content)),
+		}
+		that.docs[row.ID] = doc
+		for term, tf := range doc.terms {
+			if that.postings[term] == nil {
+				that.postings[term] = map[int]float64{}
+			}
+			that.postings[term][row.ID] = tf
+		}
+	}
+}
+
+// remove 从索引中删除书签
+func (that *relatedIndex) remove(id int) {
+	doc, ok := that.docs[id]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(that.postings[term], id)
+		if len(that.postings[term]) == 0 {
+			delete(that.postings, term)
+		}
+	}
+	delete(that.docs, id)
+}
+
+// idf 词的逆文档频率
+func (that *relatedIndex) idf(term string) float64 {
+	return math.Log(float64(len(that.docs)+1)/float64(len(that.postings[term])+1)) + 1
+}
+
+// weight 词频使用对数, 避免长正文中重复的词占比过大
+func (that *relatedIndex) weight(term string, tf float64) float64 {
+	return (1 + math.Log(tf)) * that.idf(term)
+}
+
+// docNorm 书签 TF-IDF 向量的长度, 索引改变后重新计算
+func (that *relatedIndex) docNorm(doc *relatedDoc) float64 {
+	if doc.normGen != that.gen {
+		var sum float64
+		for term, tf := range doc.terms {
+			w := that.weight(term, tf)
+			sum += w * w
+		}
+		doc.norm, doc.normGen = math.Sqrt(sum), that.gen
+	}
+	return doc.norm
+}
+
+// score 计算其他书签与 id 的相关度, 从高到低排序
+func (that *relatedIndex) score(id int, visible func(doc *relatedDoc) bool) []RelatedBookmark {
+	doc, ok := that.docs[id]
+	if !ok {
+		return nil
+	}
+
+	// 取权重最高的词, 通过倒排索引累加点积
+	type queryTerm struct {
+		term   string
+		weight float64
+	}
+	var query []queryTerm
+	for term, tf := range doc.terms {
+		query = append(query, queryTerm{term, that.weight(term, tf)})
+	}
+	sort.Slice(query, func(i, j int) bool {
+		if query[i].weight != query[j].weight {
+			return query[i].weight > query[j].weight
+		}
+		return query[i].term < query[j].term
+	})
+	if len(query) > relatedQueryTerms {
+		query = query[:relatedQueryTerms]
+	}
+	var queryNorm float64
+	dots := map[int]float64{}
+	for _, q := range query {
+		queryNorm += q.weight * q.weight
+		for other, tf := range that.postings[q.term] {
+			dots[other] += q.weight * that.weight(q.term, tf)
+		}
+	}
+	queryNorm = math.Sqrt(queryNorm)
+
+	list := []RelatedBookmark{}
+	for otherID, other := range that.docs {
+		if otherID == id || !visible(other) {
+			continue
+		}
+
+		result := RelatedBookmark{Reasons: []string{}}
+		result.ID = otherID
+		if shared := sharedTags(doc.tags, other.tags); shared > 0 {
+			result.Score += relatedTagWeight * shared
+			result.Reasons = append(result.Reasons, RelatedByTag)
+		}
+		if len(doc.domain) > 0 && doc.domain == other.domain {
+			result.Score += relatedDomainWeight
+			result.Reasons = append(result.Reasons, RelatedByDomain)
+		}
+		if dot := dots[otherID]; dot > 0 && queryNorm > 0 {
+			if norm := that.docNorm(other); norm > 0 {
+				result.Score += relatedTextWeight * math.Min(dot/(queryNorm*norm), 1)
+				result.Reasons = append(result.Reasons, RelatedByText)
+			}
+		}
+		if result.Score < relatedMinScore {
+			continue
+		}
+		result.Score = math.Round(result.Score*1000) / 1000
+		list = append(list, result)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// sharedTags 共同标签占两个书签全部标签的比例 (Jaccard)
+func sharedTags(a, b map[int]bool) float64 {
+	shared := 0
+	for id := range a {
+		if b[id] {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"bookmark/cmd/bookmark/database"
+	model2 "bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RuleActions 匹配的规则对书签的操作, 多个规则时合并标签, 公开和阅读状态以后面的规则为准
+type RuleActions struct {
+	Rules  []int    `json:"rules"` // 匹配的规则 id
+	Tags   []string `json:"tags"`
+	Public *int     `json:"public"` // nil 时不修改
+	Status string   `json:"status"` // 为空时不修改
+}
+
+type tagRulesInternal struct {
+	orm *gorm.DB
+}
+
+func NewTagRulesInternal() *tagRulesInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &tagRulesInternal{
+		orm: orm,
+	}
+}
+
+// List 自己的规则, 按 id 顺序应用
+func (that tagRulesInternal) List(uid int) []model2.TagRuleModel {
+	rules := []model2.TagRuleModel{}
+	that.orm.Where("uid = ?", uid).Order("id asc").Find(&rules)
+	return rules
+}
+
+// Info 规则详情
+func (that tagRulesInternal) Info(id int) (rule model2.TagRuleModel) {
+	that.orm.Where("id = ?", id).First(&rule)
+	return
+}
+
+// Save 新建或修改规则, 匹配条件需要有效, 至少有一个操作
+func (that tagRulesInternal) Save(rule model2.TagRuleModel) (model2.TagRuleModel, error) {
+	rule, err := normalizeRule(rule)
+	if err != nil {
+		return rule, err
+	}
+
+	now := time.Now().Format(model2.TimeLayout)
+	rule.Modified = now
+	if rule.ID > 0 {
+		that.orm.Model(&model2.TagRuleModel{}).Where("id = ?", rule.ID).Updates(map[string]interface{}{
+			"name":       rule.Name,
+			"match_type": rule.MatchType,
+			"pattern":    rule.Pattern,
+			"tags":       rule.Tags,
+			"public":     rule.Public,
+			"status":     rule.Status,
+			"enabled":    rule.Enabled,
+			"modified":   now,
+		})
+		return that.Info(rule.ID), nil
+	}
+
+	rule.Created = now
+	that.orm.Create(&rule)
+	return rule, nil
+}
+
+// Delete 删除规则
+func (that tagRulesInternal) Delete(id int) {
+	that.orm.Where("id = ?", id).Delete(&model2.TagRuleModel{})
+}
+
+// Match 账号启用的规则中匹配书签的操作, content 为正文
+func (that tagRulesInternal) Match(bookmark model2.BookmarkModel, content string) (actions RuleActions) {
+	rules := []model2.TagRuleModel{}
+	that.orm.Where("uid = ? AND enabled = 1", bookmark.Uid).Order("id asc").Find(&rules)
+
+	for _, rule := range rules {
+		matcher, err := ruleMatcher(rule)
+		if err != nil || !matcher(bookmark, content) {
+			continue
+		}
+		actions.Rules = append(actions.Rules, rule.ID)
+		actions.Tags = append(actions.Tags, ruleTags(rule)...)
+		if rule.Public >= 0 {
+			public := rule.Public
+			actions.Public = &public
+		}
+		if len(rule.Status) > 0 {
+			actions.Status = rule.Status
+		}
+	}
+	return
+}
+
+// ApplyToNew 新书签保存前应用规则, 返回加上规则标签的标签列表
+func (that tagRulesInternal) ApplyToNew(bookmark *model2.BookmarkModel, content string, tags []string) []string {
+	actions := that.Match(*bookmark, content)
+	if actions.Public != nil {
+		bookmark.Public = *actions.Public
+	}
+	if len(actions.Status) > 0 {
+		now := time.Now().Format(model2.TimeLayout)
+		bookmark.Status = actions.Status
+		switch actions.Status {
+		case model2.BookmarkStatusRead:
+			bookmark.ReadAt = now
+		case model2.BookmarkStatusArchived:
+			bookmark.ReadAt, bookmark.ArchivedAt = now, now
+		}
+	}
+	return append(tags, actions.Tags...)
+}
+
+// Preview 自己的已有书签中匹配规则的书签, 规则不需要保存
+func (that tagRulesInternal) Preview(rule model2.TagRuleModel) ([]model2.BookmarkModel, error) {
+	rule, err := normalizeRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	matcher, _ := ruleMatcher(rule)
+
+	bookmarks := []model2.BookmarkModel{}
+	that.orm.Where("uid = ?", rule.Uid).Order("id desc").Find(&bookmarks)
+
+	matched := []model2.BookmarkModel{}
+	for _, bookmark := range bookmarks {
+		content := ""
+		if rule.MatchType == model2.TagRuleMatchContent {
+			that.orm.Model(&model2.BookmarkContentModel{}).Where("docid = ?", bookmark.ID).Select("content").Scan(&content)
+		}
+		if matcher(bookmark, content) {
+			matched = append(matched, bookmark)
+		}
+	}
+	return matched, nil
+}
+
+// Apply 把规则应用到自己的已有书签: 添加标签, 修改公开和阅读状态. 返回修改的书签
+func (that tagRulesInternal) Apply(rule model2.TagRuleModel) ([]model2.BookmarkModel, error) {
+	bookmarks, err := that.Preview(rule)
+	if err != nil || len(bookmarks) == 0 {
+		return bookmarks, err
+	}
+
+	tagInternal := NewTagInternal()
+	ids := make([]int, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		ids = append(ids, bookmark.ID)
+		if tags := ruleTags(rule); len(tags) > 0 {
+			for _, tag := range tagInternal.GetTags(bookmark.Tags) {
+				if tag.ID > 0 {
+					tags = append(tags, tag.Name)
+				}
+			}
+			_, tagIds := tagInternal.InsertTag(tags)
+			tagInternal.UpdateRelationship(bookmark.ID, tagIds)
+		}
+	}
+
+	if rule.Public >= 0 {
+		that.orm.Model(&model2.BookmarkModel{}).Where("id in (?)", ids).UpdateColumn("public", rule.Public)
+		markRelatedDirty(ids...)
+	}
+	if len(rule.Status) > 0 {
+		NewBookmarksInternal().SetState(rule.Uid, ids, rule.Status, nil)
+	}
+	return bookmarks, nil
+}
+
+// normalizeRule 校验规则
+func normalizeRule(rule model2.TagRuleModel) (model2.TagRuleModel, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if len(rule.Pattern) == 0 {
+		return rule, errors.New("匹配条件不能为空")
+	}
+	if _, err := ruleMatcher(rule); err != nil {
+		return rule, err
+	}
+
+	rule.Tags = strings.Join(ruleTags(rule), ",")
+	if rule.Public > 1 || rule.Public < -1 {
+		return rule, errors.New("公开参数错误")
+	}
+	if len(rule.Status) > 0 && !ValidStatus(rule.Status) {
+		return rule, errors.New("阅读状态错误")
+	}
+	if len(rule.Tags) == 0 && rule.Public < 0 && len(rule.Status) == 0 {
+		return rule, errors.New("至少需要添加标签、设置公开或阅读状态中的一项")
+	}
+	return rule, nil
+}
+
+// ruleTags 规则添加的标签路径
+func ruleTags(rule model2.TagRuleModel) (tags []string) {
+	for _, tag := range strings.Split(rule.Tags, ",") {
+		if tag = database.NormalizeTagPath(tag); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return
+}
+
+// ruleMatcher 按匹配方式生成匹配函数, 文字匹配不区分大小写
+func ruleMatcher(rule model2.TagRuleModel) (func(bookmark model2.BookmarkModel, content string) bool, error) {
+	pattern := rule.Pattern
+	switch rule.MatchType {
+	case model2.TagRuleMatchGlob, model2.TagRuleMatchRegex:
+		expr := pattern
+		if rule.MatchType == model2.TagRuleMatchGlob {
+			expr = globRegexp(pattern)
+		}
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, errors.New("正则表达式错误: " + err.Error())
+		}
+		return func(bookmark model2.BookmarkModel, content string) bool {
+			return re.MatchString(bookmark.URL)
+		}, nil
+
+	case model2.TagRuleMatchDomain:
+		domain := database.NormalizeDomain(pattern)
+		if len(domain) == 0 || strings.ContainsAny(domain, "/?#") {
+			return nil, errors.New("域名错误, 例如 example.com")
+		}
+		return func(bookmark model2.BookmarkModel, content string) bool {
+			u, err := url.Parse(bookmark.URL)
+			if err != nil {
+				return false
+			}
+			host := strings.ToLower(u.Hostname())
+			return host == domain || strings.HasSuffix(host, "."+domain)
+		}, nil
+
+	case model2.TagRuleMatchTitle:
+		keyword := strings.ToLower(pattern)
+		return func(bookmark model2.BookmarkModel, content string) bool {
+			return strings.Contains(strings.ToLower(bookmark.Title), keyword)
+		}, nil
+
+	case model2.TagRuleMatchContent:
+		keyword := strings.ToLower(pattern)
+		return func(bookmark model2.BookmarkModel, content string) bool {
+			return strings.Contains(strings.ToLower(bookmark.Excerpt), keyword) ||
+				strings.Contains(strings.ToLower(content), keyword)
+		}, nil
+
+	case model2.TagRuleMatchContentType:
+		prefix := strings.ToLower(pattern)
+		return func(bookmark model2.BookmarkModel, content string) bool {
+			return len(bookmark.ContentType) > 0 && strings.HasPrefix(strings.ToLower(bookmark.ContentType), prefix)
+		}, nil
+	}
+	return nil, errors.New("匹配方式错误")
+}
+
+// globRegexp 通配符转为正则表达式, * 匹配任意字符, ? 匹配一个字符, 需要匹配整个 url
+func globRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
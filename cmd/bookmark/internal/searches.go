@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"bookmark/cmd/bookmark/database"
+	model2 "bookmark/cmd/bookmark/model"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// SavedSearch 保存的搜索及符合条件的书签数量
+type SavedSearch struct {
+	model2.SavedSearchModel
+	Count int64 `json:"count"`
+	Owner bool  `json:"owner"` // 是否为自己的搜索, 否则是其他账号共享的
+}
+
+type savedSearchesInternal struct {
+	orm *gorm.DB
+}
+
+func NewSavedSearchesInternal() *savedSearchesInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &savedSearchesInternal{
+		orm: orm,
+	}
+}
+
+// CanViewSavedSearch 自己的搜索和其他账号共享的搜索可以使用
+func CanViewSavedSearch(search model2.SavedSearchModel, uid int) bool {
+	if search.ID <= 0 || uid <= 0 {
+		return false
+	}
+	return search.Uid == uid || search.Shared == 1
+}
+
+// List 自己的和共享的搜索, 置顶的在前, 按名字排序, 同时统计书签数量
+func (that savedSearchesInternal) List(uid int) []SavedSearch {
+	searches := []model2.SavedSearchModel{}
+	that.orm.Where("uid = ? OR shared = 1", uid).Order("pinned desc, name asc").Find(&searches)
+
+	bookmarkInternal := NewBookmarksInternal()
+	list := make([]SavedSearch, 0, len(searches))
+	for _, search := range searches {
+		item := SavedSearch{SavedSearchModel: search, Owner: search.Uid == uid}
+		if opts, err := SavedSearchOptions(search, ""); err == nil {
+			item.Count = bookmarkInternal.Count(opts)
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+// Info 搜索详情
+func (that savedSearchesInternal) Info(id int) (search model2.SavedSearchModel) {
+	that.orm.Where("id = ?", id).First(&search)
+	return
+}
+
+// Save 新建或修改搜索, 搜索语句和排序需要有效, 同一账号下名字不能重复
+func (that savedSearchesInternal) Save(search model2.SavedSearchModel) (model2.SavedSearchModel, error) {
+	search.Name = strings.TrimSpace(search.Name)
+	if len(search.Name) == 0 {
+		return search, errors.New("名字不能为空")
+	}
+	if _, err := database.ParseQuery(search.Query); err != nil {
+		return search, errors.Wrap(err, "搜索语句错误")
+	}
+	if _, _, ok := SortOrder(search.Sort); !ok {
+		return search, errors.New("排序错误")
+	}
+
+	var count int64
+	that.orm.Model(&model2.SavedSearchModel{}).Where("uid = ? AND name = ? AND id <> ?", search.Uid, search.Name, search.ID).Count(&count)
+	if count > 0 {
+		return search, errors.New("名字已存在")
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	search.Modified = now
+	if search.ID > 0 {
+		that.orm.Model(&model2.SavedSearchModel{}).Where("id = ?", search.ID).Updates(map[string]interface{}{
+			"name":     search.Name,
+			"query":    search.Query,
+			"sort":     search.Sort,
+			"pinned":   search.Pinned,
+			"shared":   search.Shared,
+			"modified": now,
+		})
+		return that.Info(search.ID), nil
+	}
+
+	search.Created = now
+	that.orm.Create(&search)
+	return search, nil
+}
+
+// Delete 删除搜索
+func (that savedSearchesInternal) Delete(id int) {
+	that.orm.Where("id = ?", id).Delete(&model2.SavedSearchModel{})
+}
+
+// SavedSearchOptions 保存的搜索对应的查询条件, query 不为空时需要同时满足
+func SavedSearchOptions(search model2.SavedSearchModel, query string) (database.GetBookmarksOptions, error) {
+	raw := search.Query
+	if len(strings.TrimSpace(query)) > 0 {
+		raw = "(" + search.Query + ") (" + query + ")"
+		if len(strings.TrimSpace(search.Query)) == 0 {
+			raw = query
+		}
+	}
+
+	q, err := database.ParseQuery(raw)
+	if err != nil {
+		return database.GetBookmarksOptions{}, err
+	}
+	orderMethod, reverse, _ := SortOrder(search.Sort)
+	return database.GetBookmarksOptions{Query: q, OrderMethod: orderMethod, Reverse: reverse}, nil
+}
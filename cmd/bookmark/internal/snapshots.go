@@ -0,0 +1,191 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/storage"
+	"bookmark/pkg/utils"
+	"context"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+// 快照保留策略默认值, 可在配置 [snapshot] 中修改, 都为 0 时保留全部快照
+const (
+	defaultKeepLast    = 10 // 保留最近的 N 个快照
+	defaultKeepMonthly = 12 // 最近 N 个月每月保留最后一个快照
+)
+
+// 快照列表不返回正文
+const snapshotListColumns = "id, bookmark_id, created, title, excerpt, author, published, site_name, content_type, image_url, archive, warc"
+
+type snapshotsInternal struct {
+	orm *gorm.DB
+}
+
+func NewSnapshotsInternal() *snapshotsInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &snapshotsInternal{
+		orm: orm,
+	}
+}
+
+// SnapshotDiff 两个快照正文的差异
+type SnapshotDiff struct {
+	From    model2.BookmarkSnapshotModel `json:"from"`
+	To      model2.BookmarkSnapshotModel `json:"to"`
+	Added   int                          `json:"added"`
+	Removed int                          `json:"removed"`
+	Chunks  []utils.DiffChunk            `json:"chunks"`
+}
+
+// Create 保存一次抓取的快照
+func (that snapshotsInternal) Create(bookmark model2.BookmarkModel, content string) model2.BookmarkSnapshotModel {
+	snapshot := model2.BookmarkSnapshotModel{
+		BookmarkId:  bookmark.ID,
+		Created:     time.Now().Format("2006-01-02 15:04:05"),
+		Title:       bookmark.Title,
+		Excerpt:     bookmark.Excerpt,
+		Author:      bookmark.Author,
+		Published:   bookmark.Published,
+		SiteName:    bookmark.SiteName,
+		ContentType: bookmark.ContentType,
+		ImageURL:    bookmark.ImageURL,
+		Content:     content,
+	}
+	that.orm.Create(&snapshot)
+	return snapshot
+}
+
+// List 书签的快照列表, 最新的在前
+func (that snapshotsInternal) List(bookmarkId int) (list []model2.BookmarkSnapshotModel) {
+	that.orm.Select(snapshotListColumns).Where("bookmark_id = ?", bookmarkId).Order("id desc").Find(&list)
+	return
+}
+
+// Info 快照详情, 包括正文
+func (that snapshotsInternal) Info(id int) (snapshot model2.BookmarkSnapshotModel) {
+	that.orm.Where("id = ?", id).First(&snapshot)
+	return
+}
+
+// Diff 按行比较两个快照的正文
+func (that snapshotsInternal) Diff(from, to model2.BookmarkSnapshotModel) SnapshotDiff {
+	diff := SnapshotDiff{
+		Chunks: utils.DiffLines(from.Content, to.Content),
+	}
+	for _, chunk := range diff.Chunks {
+		switch chunk.Type {
+		case utils.DiffInsert:
+			diff.Added += len(chunk.Lines)
+		case utils.DiffDelete:
+			diff.Removed += len(chunk.Lines)
+		}
+	}
+
+	from.Content, to.Content = "", ""
+	diff.From, diff.To = from, to
+	return diff
+}
+
+// Prune 按保留策略删除旧快照: 保留最近 keep_last 个, 以及最近 keep_monthly 个月中每月最后一个
+func (that snapshotsInternal) Prune(bookmarkId int) {
+	keepLast, keepMonthly := defaultKeepLast, defaultKeepMonthly
+	if viper.IsSet("snapshot.keep_last") {
+		keepLast = viper.GetInt("snapshot.keep_last")
+	}
+	if viper.IsSet("snapshot.keep_monthly") {
+		keepMonthly = viper.GetInt("snapshot.keep_monthly")
+	}
+	if keepLast <= 0 && keepMonthly <= 0 {
+		return
+	}
+
+	list := that.List(bookmarkId)
+	if len(list) == 0 {
+		return
+	}
+
+	// 最新的快照总是保留
+	keep := map[int]bool{list[0].ID: true}
+	months := map[string]bool{}
+	for i, snapshot := range list {
+		if i < keepLast {
+			keep[snapshot.ID] = true
+		}
+
+		month := snapshot.Created
+		if len(month) >= 7 {
+			month = month[:7]
+		}
+		if !months[month] && len(months) < keepMonthly {
+			months[month] = true
+			keep[snapshot.ID] = true
+		}
+	}
+
+	var removed []model2.BookmarkSnapshotModel
+	for _, snapshot := range list {
+		if !keep[snapshot.ID] {
+			removed = append(removed, snapshot)
+		}
+	}
+	that.delete(removed)
+}
+
+// DeleteByBookmark 删除书签的全部快照
+func (that snapshotsInternal) DeleteByBookmark(bookmarkId int) {
+	that.delete(that.List(bookmarkId))
+}
+
+// delete 删除快照记录, 以及不再被引用的截图和存档文件
+func (that snapshotsInternal) delete(snapshots []model2.BookmarkSnapshotModel) {
+	if len(snapshots) == 0 {
+		return
+	}
+
+	var ids []int
+	for _, snapshot := range snapshots {
+		ids = append(ids, snapshot.ID)
+	}
+	that.orm.Where("id in (?)", ids).Delete(&model2.BookmarkSnapshotModel{})
+
+	for _, snapshot := range snapshots {
+		that.RemoveUnusedImage(snapshot.ImageURL)
+		that.RemoveUnusedArchive(snapshot.Archive)
+		that.RemoveUnusedArchive(snapshot.Warc)
+	}
+}
+
+// RemoveUnusedImage 截图不再被书签或快照引用时删除
+func (that snapshotsInternal) RemoveUnusedImage(name string) {
+	if len(name) == 0 || that.referenced("image_url", name) {
+		return
+	}
+	if err := storage.Default().Delete(context.Background(), ImageKey(name)); err != nil {
+		log.Println("remove image", name, err)
+	}
+	RemoveImageVariants(name)
+}
+
+// RemoveUnusedArchive 存档或 WARC 文件不再被书签或快照引用时删除
+func (that snapshotsInternal) RemoveUnusedArchive(name string) {
+	if len(name) == 0 || that.referenced("archive", name) || that.referenced("warc", name) {
+		return
+	}
+	if err := storage.Default().Delete(context.Background(), ArchiveKey(name)); err != nil {
+		log.Println("remove archive", name, err)
+	}
+}
+
+func (that snapshotsInternal) referenced(column, name string) bool {
+	var count int64
+	that.orm.Model(&model2.BookmarkModel{}).Where(column+" = ?", name).Count(&count)
+	if count > 0 {
+		return true
+	}
+	that.orm.Model(&model2.BookmarkSnapshotModel{}).Where(column+" = ?", name).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"bookmark/pkg/utils"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SuggestLimit 默认推荐的标签数量
+const SuggestLimit = 10
+
+// 推荐标签的权重: 同域名书签的标签、关键字相似书签的标签、最近使用的标签
+const (
+	suggestDomainWeight  = 0.5
+	suggestKeywordWeight = 0.35
+	suggestRecentWeight  = 0.15
+
+	suggestRecentBookmarks = 20 // 最近添加的书签数量
+)
+
+// 推荐的原因
+const (
+	SuggestByDomain  = "domain"
+	SuggestByKeyword = "keyword"
+	SuggestByRecent  = "recent"
+)
+
+// TagSuggestion 推荐的标签, score 在 0 到 1 之间
+type TagSuggestion struct {
+	Tag     string   `json:"tag"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// taggedDoc 有标签的书签
+type taggedDoc struct {
+	url   string
+	text  string
+	tags  []string
+	terms map[string]float64
+}
+
+// SuggestTags 保存前给链接推荐标签, 只使用自己已有的书签在本地计算:
+// 同域名书签中标签的使用比例, 标题和摘要与已有书签的 TF-IDF 相似度, 最近添加的书签的标签
+func (that bookmarksInternal) SuggestTags(uid int, uri, title, excerpt string, limit int) []TagSuggestion {
+	if limit <= 0 {
+		limit = SuggestLimit
+	}
+
+	var rows []struct {
+		ID      int
+		URL     string
+		Title   string
+		Excerpt string
+		Tag     string
+	}
+	that.orm.Table("bookmark b").
+		Select("b.id, b.url, b.title, b.excerpt, t.name tag").
+		Joins("JOIN bookmark_tag bt ON bt.bookmark_id = b.id").
+		Joins("JOIN tag t ON t.id = bt.tag_id").
+		Where("b.uid = ?", uid).
+		Order("b.created_at desc, b.id desc, bt.rowid").
+		Scan(&rows)
+
+	// 按书签分组, 保持从新到旧
+	var docs []*taggedDoc
+	byID := map[int]*taggedDoc{}
+	for _, row := range rows {
+		doc, ok := byID[row.ID]
+		if !ok {
+			doc = &taggedDoc{url: row.URL, text: row.Title + " " + row.Excerpt}
+			byID[row.ID] = doc
+			docs = append(docs, doc)
+		}
+		doc.tags = append(doc.tags, row.Tag)
+	}
+
+	domain := suggestByDomain(docs, utils.GetDomain(uri))
+	keyword := normalizedScores(suggestByKeyword(docs, title+" "+excerpt+" "+urlWords(uri)))
+	recent := normalizedScores(suggestByRecent(docs))
+
+	suggestions := []TagSuggestion{}
+	seen := map[string]bool{}
+	for _, scores := range []map[string]float64{domain, keyword, recent} {
+		for tag := range scores {
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+
+			suggestion := TagSuggestion{Tag: tag, Reasons: []string{}}
+			if score := domain[tag]; score > 0 {
+				suggestion.Score += suggestDomainWeight * score
+				suggestion.Reasons = append(suggestion.Reasons, SuggestByDomain)
+			}
+			if score := keyword[tag]; score > 0 {
+				suggestion.Score += suggestKeywordWeight * score
+				suggestion.Reasons = append(suggestion.Reasons, SuggestByKeyword)
+			}
+			if score := recent[tag]; score > 0 {
+				suggestion.Score += suggestRecentWeight * score
+				suggestion.Reasons = append(suggestion.Reasons, SuggestByRecent)
+			}
+			suggestion.Score = math.Round(suggestion.Score*1000) / 1000
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Tag < suggestions[j].Tag
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// suggestByDomain 同域名 (包括子域名) 书签中使用标签的比例
+func suggestByDomain(docs []*taggedDoc, domain string) map[string]float64 {
+	scores := map[string]float64{}
+	if len(domain) == 0 {
+		return scores
+	}
+	total := 0
+	for _, doc := range docs {
+		host := utils.GetDomain(doc.url)
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+		total++
+		for _, tag := range doc.tags {
+			scores[tag]++
+		}
+	}
+	for tag := range scores {
+		scores[tag] /= float64(total)
+	}
+	return scores
+}
+
+// suggestByKeyword 按 TF-IDF 余弦相似度累加相似书签的标签
+func suggestByKeyword(docs []*taggedDoc, text string) map[string]float64 {
+	scores := map[string]float64{}
+	query := termFrequency(utils.Tokenize(text))
+	if len(query) == 0 || len(docs) == 0 {
+		return scores
+	}
+
+	df := map[string]int{}
+	for _, doc := range docs {
+		doc.terms = termFrequency(utils.Tokenize(doc.text))
+		for term := range doc.terms {
+			df[term]++
+		}
+	}
+	idf := func(term string) float64 {
+		return math.Log(float64(len(docs)+1)/float64(df[term]+1)) + 1
+	}
+
+	queryVector := tfidfVector(query, idf)
+	for _, doc := range docs {
+		similarity := cosine(queryVector, tfidfVector(doc.terms, idf))
+		if similarity <= 0 {
+			continue
+		}
+		for _, tag := range doc.tags {
+			scores[tag] += similarity
+		}
+	}
+	return scores
+}
+
+// suggestByRecent 最近添加的书签的标签, 越新权重越高
+func suggestByRecent(docs []*taggedDoc) map[string]float64 {
+	scores := map[string]float64{}
+	for i, doc := range docs {
+		if i >= suggestRecentBookmarks {
+			break
+		}
+		for _, tag := range doc.tags {
+			scores[tag] += float64(suggestRecentBookmarks-i) / suggestRecentBookmarks
+		}
+	}
+	return scores
+}
+
+// urlWords 链接的路径, 分词后和标题、摘要一起匹配, 例如 /blog/go-generics
+func urlWords(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+func termFrequency(tokens []string) map[string]float64 {
+	tf := map[string]float64{}
+	for _, token := range tokens {
+		tf[token]++
+	}
+	return tf
+}
+
+func tfidfVector(tf map[string]float64, idf func(term string) float64) map[string]float64 {
+	vector := make(map[string]float64, len(tf))
+	for term, count := range tf {
+		vector[term] = count * idf(term)
+	}
+	return vector
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, x := range a {
+		normA += x * x
+		dot += x * b[term]
+	}
+	for _, y := range b {
+		normB += y * y
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// normalizedScores 按最高分缩放到 0 到 1
+func normalizedScores(scores map[string]float64) map[string]float64 {
+	var max float64
+	for _, score := range scores {
+		max = math.Max(max, score)
+	}
+	if max <= 0 {
+		return map[string]float64{}
+	}
+	for tag := range scores {
+		scores[tag] /= max
+	}
+	return scores
+}
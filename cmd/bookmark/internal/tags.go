@@ -1,9 +1,14 @@
 package internal
 
 import (
+	"bookmark/cmd/bookmark/database"
 	model2 "bookmark/cmd/bookmark/model"
 	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"path"
 	"strings"
+	"unicode/utf8"
 )
 
 type tagsInternal struct {
@@ -13,16 +18,46 @@ func NewTagInternal() *tagsInternal {
 	return &tagsInternal{}
 }
 
-func (that tagsInternal) GetList() (tagModels []model2.TagModel) {
+// TagNode 标签及书签数量, Count 为直接使用该标签的书签数, Total 包括下级标签
+type TagNode struct {
+	model2.TagModel
+	Count int `json:"count"`
+	Total int `json:"total"`
+}
+
+// GetList 有书签的标签, 以及它们的上级标签, 按路径排序, 通过 parentId 组成树
+func (that tagsInternal) GetList() []TagNode {
 	orm, _ := igorm.GetGormSQLite("cache")
-	tags := []model2.TagModel{}
+	tags := []TagNode{}
 
-	orm.Raw(`SELECT bt.tag_id id, t.name 
-		FROM bookmark_tag bt
-		LEFT JOIN tag t ON bt.tag_id = t.id
+	orm.Raw(`SELECT t.id, t.name, t.parent_id, COUNT(bt.bookmark_id) count
+		FROM tag t
+		LEFT JOIN bookmark_tag bt ON bt.tag_id = t.id
 		GROUP BY t.id ORDER BY t.name`).Scan(&tags)
 
-	return tags
+	// 书签数累加到上级标签
+	index := map[int]int{}
+	for i, tag := range tags {
+		index[tag.ID] = i
+	}
+	for _, tag := range tags {
+		for id, depth := tag.ID, 0; depth < len(tags); depth++ {
+			i, ok := index[id]
+			if !ok {
+				break
+			}
+			tags[i].Total += tag.Count
+			id = tags[i].ParentId
+		}
+	}
+
+	list := []TagNode{}
+	for _, tag := range tags {
+		if tag.Total > 0 {
+			list = append(list, tag)
+		}
+	}
+	return list
 }
 
 func (that tagsInternal) GetTags(tagIds string) (tagModels []model2.TagModel) {
@@ -39,20 +74,99 @@ func (that tagsInternal) GetTags(tagIds string) (tagModels []model2.TagModel) {
 	return tags
 }
 
+// InsertTag 按路径保存标签, 缺少的上级标签一并创建, 返回路径最末级的标签
 func (that tagsInternal) InsertTag(tags []string) (tagModels []model2.TagModel, tagIds []int) {
 	orm, _ := igorm.GetGormSQLite("cache")
+	seen := map[int]bool{}
 	for _, name := range tags {
-		name = strings.TrimSpace(name)
-		tagmodel := model2.TagModel{
-			Name: name,
+		tagmodel := that.ensurePath(orm, database.NormalizeTagPath(name))
+		if tagmodel.ID <= 0 || seen[tagmodel.ID] {
+			continue
 		}
-		orm.Where("name = ?", name).FirstOrCreate(&tagmodel)
+		seen[tagmodel.ID] = true
 		tagModels = append(tagModels, tagmodel)
 		tagIds = append(tagIds, tagmodel.ID)
 	}
 	return
 }
 
+// ensurePath 从最上级开始逐级查找或创建标签
+func (that tagsInternal) ensurePath(orm *gorm.DB, tagPath string) (tagmodel model2.TagModel) {
+	if len(tagPath) == 0 {
+		return
+	}
+	segments := strings.Split(tagPath, "/")
+	for i := range segments {
+		parentId := tagmodel.ID
+		tagmodel = model2.TagModel{
+			Name:     strings.Join(segments[:i+1], "/"),
+			ParentId: parentId,
+		}
+		orm.Where("name = ?", tagmodel.Name).First(&tagmodel)
+		if tagmodel.ID <= 0 && orm.Create(&tagmodel).Error == nil {
+			EmitWebhook(model2.WebhookEventTagCreated, tagmodel)
+		}
+	}
+	return
+}
+
+// Move 把标签及其下级标签移动到 parentId 下, parentId 为 0 时移动到最上级, name 为空时保留原来的名字
+func (that tagsInternal) Move(id, parentId int, name string) (model2.TagModel, error) {
+	orm, _ := igorm.GetGormSQLite("cache")
+
+	tag := model2.TagModel{}
+	orm.Where("id = ?", id).First(&tag)
+	if tag.ID <= 0 {
+		return tag, errors.New("标签不存在")
+	}
+
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		name = path.Base(tag.Name)
+	}
+	if strings.Contains(name, "/") {
+		return tag, errors.New("标签名不能包含 /")
+	}
+
+	newPath := name
+	if parentId > 0 {
+		parent := model2.TagModel{}
+		orm.Where("id = ?", parentId).First(&parent)
+		if parent.ID <= 0 {
+			return tag, errors.New("上级标签不存在")
+		}
+		if parent.ID == tag.ID || strings.HasPrefix(parent.Name, tag.Name+"/") {
+			return tag, errors.New("不能移动到自己的下级标签")
+		}
+		newPath = parent.Name + "/" + name
+	}
+	if newPath == tag.Name {
+		return tag, nil
+	}
+
+	var count int64
+	orm.Model(&model2.TagModel{}).Where("name = ?", newPath).Count(&count)
+	if count > 0 {
+		return tag, errors.New("目标标签已存在")
+	}
+
+	// 下级标签的路径前缀一起替换, id 不变, 书签关系不受影响. substr 按字符计算
+	err := orm.Transaction(func(tx *gorm.DB) error {
+		length := utf8.RuneCountInString(tag.Name)
+		if err := tx.Exec(`UPDATE tag SET name = ? || substr(name, ?) WHERE id = ? OR substr(name, 1, ?) = ?`,
+			newPath, length+1, tag.ID, length+1, tag.Name+"/").Error; err != nil {
+			return err
+		}
+		return tx.Model(&model2.TagModel{}).Where("id = ?", tag.ID).Update("parent_id", parentId).Error
+	})
+	if err != nil {
+		return tag, errors.WithStack(err)
+	}
+
+	tag.Name, tag.ParentId = newPath, parentId
+	return tag, nil
+}
+
 func (that tagsInternal) UpdateRelationship(bookmarkId int, tagIds []int) {
 	orm, _ := igorm.GetGormSQLite("cache")
 	// 删除之前关系
@@ -65,4 +179,7 @@ func (that tagsInternal) UpdateRelationship(bookmarkId int, tagIds []int) {
 		}
 		orm.Where("bookmark_id = ? and tag_id = ?", bookmarkId, id).FirstOrCreate(&modelBt)
 	}
+
+	// 写入新的关系后再标记, 避免并发查询按没有标签的状态重新索引
+	markRelatedDirty(bookmarkId)
 }
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/storage"
+	"bookmark/pkg/warc"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WarcEnabled 是否在抓取网页时记录 WARC, 配置 [archive] warc = true
+func WarcEnabled() bool {
+	return viper.GetBool("archive.warc")
+}
+
+// warcRecorder 在内存中收集抓取网页和存档资源时的请求和响应
+type warcRecorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   *warc.Writer
+}
+
+func newWarcRecorder() *warcRecorder {
+	rec := &warcRecorder{}
+	rec.w = warc.NewWriter(&rec.buf)
+	return rec
+}
+
+// record 实现 fetch.Recorder
+func (rec *warcRecorder) record(req *http.Request, resp *http.Response, body []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if err := rec.w.WriteExchange(req, resp, body, time.Now()); err != nil {
+		log.Println("warc record", req.URL, err)
+	}
+}
+
+// save 保存到存储的 consts.ArchiveDir, 返回文件名
+func (rec *warcRecorder) save(name string) (string, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.buf.Len() == 0 {
+		return "", errors.New("empty warc")
+	}
+
+	name += ".warc.gz"
+	var file bytes.Buffer
+	if _, err := warc.NewWriter(&file).WriteInfo(name, warcInfoFields()); err != nil {
+		return "", err
+	}
+	file.Write(rec.buf.Bytes())
+
+	if err := storage.PutBytes(context.Background(), storage.Default(), ArchiveKey(name), file.Bytes(), warc.ContentType); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ExportWarc 导出书签的 WARC 记录. 没有抓取记录的书签, 使用网页存档生成 resource 记录.
+// 书签有笔记或高亮时, 另外写入 metadata 记录.
+func ExportWarc(w io.Writer, filename string, bookmarks []model2.BookmarkModel) error {
+	writer := warc.NewWriter(w)
+	if _, err := writer.WriteInfo(filename, warcInfoFields()); err != nil {
+		return err
+	}
+
+	notesInternal := NewNotesInternal()
+	for _, bookmark := range bookmarks {
+		if err := exportWarcArchive(w, writer, bookmark); err != nil {
+			return err
+		}
+
+		annotations := notesInternal.Export(bookmark.ID)
+		if len(annotations.Notes) == 0 && len(annotations.Highlights) == 0 {
+			continue
+		}
+		data, err := json.Marshal(annotations)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := writer.WriteMetadata(bookmark.URL, "application/json", data, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportWarcArchive 写入书签的抓取记录或网页存档, 文件读取失败时跳过
+func exportWarcArchive(w io.Writer, writer *warc.Writer, bookmark model2.BookmarkModel) error {
+	ctx := context.Background()
+	if len(bookmark.Warc) > 0 {
+		if err := copyObject(ctx, w, ArchiveKey(bookmark.Warc)); err == nil {
+			return nil
+		} else {
+			log.Println("export warc", bookmark.ID, err)
+		}
+	}
+
+	if len(bookmark.Archive) == 0 {
+		return nil
+	}
+	data, info, err := storage.ReadAll(ctx, storage.Default(), ArchiveKey(bookmark.Archive))
+	if err != nil {
+		log.Println("export warc", bookmark.ID, err)
+		return nil
+	}
+	return writer.WriteResource(bookmark.URL, "text/html; charset=utf-8", data, info.ModTime)
+}
+
+func warcInfoFields() map[string]string {
+	return map[string]string{
+		"software":    "bookmark",
+		"conformsTo":  "http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/",
+		"description": "bookmark archive",
+	}
+}
+
+func copyObject(ctx context.Context, w io.Writer, key string) error {
+	body, _, err := storage.Default().Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	_, err = io.Copy(w, body)
+	return errors.WithStack(err)
+}
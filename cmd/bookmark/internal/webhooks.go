@@ -0,0 +1,390 @@
+package internal
+
+import (
+	model2 "bookmark/cmd/bookmark/model"
+	"bookmark/pkg/fetch"
+	"bookmark/pkg/imiddleware"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/cute-angelia/go-utils/components/igorm"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhook 默认配置, 可在配置 [webhook] 中修改
+const (
+	defaultWebhookTimeout        = time.Second * 10
+	defaultWebhookMaxAttempts    = 6
+	defaultWebhookRetryBackoff   = time.Second * 30 // 首次重试等待时间, 之后每次翻倍
+	defaultWebhookKeepDeliveries = 200              // 每个 webhook 保留的投递记录
+
+	webhookPollInterval    = time.Second * 5
+	webhookBatchSize       = 50
+	webhookMaxResponseBody = 1024
+)
+
+// WebhookPayload webhook 请求体
+type WebhookPayload struct {
+	Event   string      `json:"event"`
+	Created int64       `json:"created"`
+	Data    interface{} `json:"data"`
+}
+
+type webhooksInternal struct {
+	orm *gorm.DB
+}
+
+func NewWebhooksInternal() *webhooksInternal {
+	orm, _ := igorm.GetGormSQLite("cache")
+	return &webhooksInternal{
+		orm: orm,
+	}
+}
+
+var (
+	webhookWorkerOnce sync.Once
+	webhookWake       = make(chan struct{}, 1)
+	webhookClientOnce sync.Once
+	webhookClient     *http.Client
+)
+
+// List 全部 webhook
+func (that webhooksInternal) List() []model2.WebhookModel {
+	hooks := []model2.WebhookModel{}
+	that.orm.Order("id asc").Find(&hooks)
+	return hooks
+}
+
+// Info webhook 详情
+func (that webhooksInternal) Info(id int) (hook model2.WebhookModel) {
+	that.orm.Where("id = ?", id).First(&hook)
+	return
+}
+
+// Save 新建或修改 webhook, 新建时没有密钥会随机生成
+func (that webhooksInternal) Save(hook model2.WebhookModel) (model2.WebhookModel, error) {
+	hook.URL = strings.TrimSpace(hook.URL)
+	if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return hook, errors.New("地址需要以 http:// 或 https:// 开头")
+	}
+	events, err := normalizeWebhookEvents(hook.Events)
+	if err != nil {
+		return hook, err
+	}
+	hook.Events = events
+
+	now := time.Now().Format(model2.TimeLayout)
+	hook.Modified = now
+	if hook.ID > 0 {
+		updates := map[string]interface{}{
+			"url":      hook.URL,
+			"events":   hook.Events,
+			"enabled":  hook.Enabled,
+			"modified": now,
+		}
+		if len(hook.Secret) > 0 {
+			updates["secret"] = hook.Secret
+		}
+		that.orm.Model(&model2.WebhookModel{}).Where("id = ?", hook.ID).Updates(updates)
+		return that.Info(hook.ID), nil
+	}
+
+	if len(hook.Secret) == 0 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return hook, errors.WithStack(err)
+		}
+		hook.Secret = hex.EncodeToString(secret)
+	}
+	hook.Created = now
+	that.orm.Create(&hook)
+	return hook, nil
+}
+
+// Delete 删除 webhook 和投递记录
+func (that webhooksInternal) Delete(id int) {
+	that.orm.Where("webhook_id = ?", id).Delete(&model2.WebhookDeliveryModel{})
+	that.orm.Where("id = ?", id).Delete(&model2.WebhookModel{})
+}
+
+// Deliveries webhook 的投递记录, 从新到旧
+func (that webhooksInternal) Deliveries(webhookId, limit int) []model2.WebhookDeliveryModel {
+	deliveries := []model2.WebhookDeliveryModel{}
+	that.orm.Where("webhook_id = ?", webhookId).Order("id desc").Limit(limit).Find(&deliveries)
+	return deliveries
+}
+
+// DeliveryInfo 投递记录详情
+func (that webhooksInternal) DeliveryInfo(id int) (delivery model2.WebhookDeliveryModel) {
+	that.orm.Where("id = ?", id).First(&delivery)
+	return
+}
+
+// Redeliver 重新投递, 重试次数清零
+func (that webhooksInternal) Redeliver(id int) {
+	that.orm.Model(&model2.WebhookDeliveryModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          model2.WebhookDeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": 0,
+		"modified":        time.Now().Format(model2.TimeLayout),
+	})
+	wakeWebhookWorker()
+}
+
+// EmitWebhook 为订阅了事件的 webhook 记录投递, 由后台任务发送
+func EmitWebhook(event string, data interface{}) {
+	orm, _ := igorm.GetGormSQLite("cache")
+	hooks := []model2.WebhookModel{}
+	orm.Where("enabled = 1").Find(&hooks)
+
+	var payload []byte
+	now := time.Now()
+	for _, hook := range hooks {
+		if !webhookSubscribed(hook, event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(WebhookPayload{Event: event, Created: now.Unix(), Data: data}); err != nil {
+				log.Println("webhook payload", event, err)
+				return
+			}
+		}
+		orm.Create(&model2.WebhookDeliveryModel{
+			WebhookId:     hook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        model2.WebhookDeliveryPending,
+			NextAttemptAt: now.Unix(),
+			Created:       now.Format(model2.TimeLayout),
+			Modified:      now.Format(model2.TimeLayout),
+		})
+	}
+	if payload != nil {
+		wakeWebhookWorker()
+	}
+}
+
+// emitBookmarkWebhook 书签事件, 数据包括标签详情
+func emitBookmarkWebhook(event string, bookmark model2.BookmarkModel) {
+	bookmark.TagsDetail = nil
+	EmitWebhook(event, NewBookmarksInternal().FillTagsDetail([]model2.BookmarkModel{bookmark})[0])
+}
+
+// StartWebhookWorker 启动后台投递, 包括重启前未完成的投递
+func StartWebhookWorker(ctx context.Context) {
+	webhookWorkerOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(webhookPollInterval)
+			defer ticker.Stop()
+			for {
+				NewWebhooksInternal().DeliverDue(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case <-webhookWake:
+				}
+			}
+		}()
+	})
+}
+
+func wakeWebhookWorker() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverDue 发送到期的投递
+func (that webhooksInternal) DeliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries := []model2.WebhookDeliveryModel{}
+		that.orm.Where("status = ? AND next_attempt_at <= ?", model2.WebhookDeliveryPending, time.Now().Unix()).
+			Order("id asc").Limit(webhookBatchSize).Find(&deliveries)
+		if len(deliveries) == 0 {
+			return
+		}
+
+		pruned := map[int]bool{}
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+			that.deliver(ctx, delivery)
+			if !pruned[delivery.WebhookId] {
+				pruned[delivery.WebhookId] = true
+				that.prune(delivery.WebhookId)
+			}
+		}
+	}
+}
+
+// deliver 发送一次, 失败时按退避时间安排重试, 超过次数后标记为失败
+func (that webhooksInternal) deliver(ctx context.Context, delivery model2.WebhookDeliveryModel) {
+	hook := that.Info(delivery.WebhookId)
+	if hook.ID <= 0 {
+		that.orm.Where("id = ?", delivery.ID).Delete(&model2.WebhookDeliveryModel{})
+		return
+	}
+
+	delivery.Attempts++
+	delivery.ResponseCode, delivery.ResponseBody, delivery.Error = 0, "", ""
+
+	code, body, err := postWebhook(ctx, hook, delivery)
+	delivery.ResponseCode, delivery.ResponseBody = code, body
+	switch {
+	case err == nil && code >= 200 && code < 300:
+		delivery.Status = model2.WebhookDeliverySuccess
+	case delivery.Attempts >= webhookMaxAttempts():
+		delivery.Status = model2.WebhookDeliveryFailed
+	default:
+		shift := delivery.Attempts - 1
+		if shift > 10 {
+			shift = 10
+		}
+		delivery.NextAttemptAt = time.Now().Add(webhookRetryBackoff() << shift).Unix()
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	} else if delivery.Status != model2.WebhookDeliverySuccess {
+		delivery.Error = "HTTP " + strconv.Itoa(code)
+	}
+	if delivery.Status == model2.WebhookDeliveryFailed {
+		log.Println("webhook", hook.URL, delivery.Event, delivery.Error)
+	}
+
+	that.orm.Model(&model2.WebhookDeliveryModel{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_code":   delivery.ResponseCode,
+		"response_body":   delivery.ResponseBody,
+		"error":           delivery.Error,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"modified":        time.Now().Format(model2.TimeLayout),
+	})
+}
+
+// prune 只保留最近的投递记录, 未完成的投递不删除
+func (that webhooksInternal) prune(webhookId int) {
+	keep := viper.GetInt("webhook.keep_deliveries")
+	if keep <= 0 {
+		keep = defaultWebhookKeepDeliveries
+	}
+	that.orm.Where("webhook_id = ? AND status <> ? AND id NOT IN (?)", webhookId, model2.WebhookDeliveryPending,
+		that.orm.Model(&model2.WebhookDeliveryModel{}).Select("id").Where("webhook_id = ?", webhookId).Order("id desc").Limit(keep),
+	).Delete(&model2.WebhookDeliveryModel{})
+}
+
+// postWebhook 发送请求, 签名在 X-Bookmark-Signature 中, 不跟随跳转
+func postWebhook(ctx context.Context, hook model2.WebhookModel, delivery model2.WebhookDeliveryModel) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookmark-webhook")
+	req.Header.Set("X-Bookmark-Event", delivery.Event)
+	req.Header.Set("X-Bookmark-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Bookmark-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Bookmark-Signature", "sha256="+imiddleware.HmacSign(hook.Secret, timestamp, payload))
+
+	resp, err := getWebhookClient().Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseBody))
+	return resp.StatusCode, string(body), nil
+}
+
+// getWebhookClient 和网页抓取一样拒绝内网地址, 配置 [webhook] allow_private 后允许
+func getWebhookClient() *http.Client {
+	webhookClientOnce.Do(func() {
+		timeout := viper.GetDuration("webhook.timeout")
+		if timeout <= 0 {
+			timeout = defaultWebhookTimeout
+		}
+		dialer := &net.Dialer{Timeout: timeout}
+		if !viper.GetBool("webhook.allow_private") {
+			dialer.Control = fetch.GuardControl
+		}
+		webhookClient = &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	})
+	return webhookClient
+}
+
+func webhookMaxAttempts() int {
+	if attempts := viper.GetInt("webhook.max_attempts"); attempts > 0 {
+		return attempts
+	}
+	return defaultWebhookMaxAttempts
+}
+
+func webhookRetryBackoff() time.Duration {
+	if backoff := viper.GetDuration("webhook.retry_backoff"); backoff > 0 {
+		return backoff
+	}
+	return defaultWebhookRetryBackoff
+}
+
+// normalizeWebhookEvents 校验订阅的事件, * 为全部事件
+func normalizeWebhookEvents(events string) (string, error) {
+	var list []string
+	seen := map[string]bool{}
+	for _, event := range strings.Split(events, ",") {
+		event = strings.TrimSpace(event)
+		if len(event) == 0 || seen[event] {
+			continue
+		}
+		if event == "*" {
+			return "*", nil
+		}
+		valid := false
+		for _, known := range model2.WebhookEvents {
+			valid = valid || known == event
+		}
+		if !valid {
+			return "", errors.New("未知的事件: " + event)
+		}
+		seen[event] = true
+		list = append(list, event)
+	}
+	if len(list) == 0 {
+		return "", errors.New("至少需要订阅一个事件")
+	}
+	return strings.Join(list, ","), nil
+}
+
+func webhookSubscribed(hook model2.WebhookModel, event string) bool {
+	for _, subscribed := range strings.Split(hook.Events, ",") {
+		if subscribed == "*" || subscribed == event {
+			return true
+		}
+	}
+	return false
+}
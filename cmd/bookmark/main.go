@@ -5,13 +5,19 @@ import (
 	"bookmark/cmd/bookmark/controller/auth"
 	"bookmark/cmd/bookmark/controller/bookmarks"
 	"bookmark/cmd/bookmark/controller/tags"
+	"bookmark/cmd/bookmark/controller/webhooks"
 	"bookmark/cmd/bookmark/database"
+	"bookmark/cmd/bookmark/internal"
+	"bookmark/cmd/bookmark/internal/consts"
 	"bookmark/cmd/bookmark/model"
 	"bookmark/pkg/configV2"
 	"bookmark/pkg/env"
+	"bookmark/pkg/fetch"
 	"bookmark/pkg/imiddleware"
+	"bookmark/pkg/storage"
 	"context"
 	"embed"
+	"flag"
 	"fmt"
 	"github.com/cute-angelia/go-utils/components/caches/ibunt"
 	"github.com/cute-angelia/go-utils/components/igorm"
@@ -45,6 +51,22 @@ func main() {
 	// 初始化配置
 	configV2.InitConfig("")
 
+	// 初始化网页抓取
+	fetch.Init(fetch.LoadConfig())
+
+	// 初始化文件存储
+	store, err := storage.New(storage.LoadConfig())
+	if err != nil {
+		log.Println("storage.New", err)
+		os.Exit(1)
+	}
+	storage.Init(store)
+
+	// 在存储之间迁移文件, 例如: bookmark migrate-storage -from local -to s3
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		os.Exit(migrateStorage(os.Args[2:]))
+	}
+
 	// 初始化 buntcache
 	ibunt.New()
 
@@ -59,6 +81,9 @@ func main() {
 	if err := database.Dbx.Migrate(); err != nil {
 		log.Println("db.Migrate", err)
 	}
+	if err := database.Dbx.InitSearchIndex(context.Background()); err != nil {
+		log.Println("全文索引不可用, 搜索使用 LIKE", err)
+	}
 
 	accounts, err := database.Dbx.GetAccounts(context.Background(), database.GetAccountsOptions{})
 	if err != nil {
@@ -86,6 +111,9 @@ func main() {
 		igorm.WithLoggerWriter(loggerV3.GetLogger()),
 	).MustInitSqlite()
 
+	// 后台发送 webhook, 包括重启前未完成的投递
+	internal.StartWebhookWorker(context.Background())
+
 	// 定义路由
 	r := chi.NewRouter()
 	corsz := cors.New(cors.Options{
@@ -109,7 +137,8 @@ func main() {
 		"/",
 		"/login.html",
 		"/assets/.*",
-		"/api/bookmarks/showShot",
+		// 截图使用签名地址, 在接口中校验
+		"^/api/bookmarks/[0-9]+/image$",
 	}), imiddleware.Log([]string{}))
 
 	// 路由组
@@ -121,6 +150,7 @@ func main() {
 			r.Mount("/bookmarks", bookmarks.Bookmarks{}.Routes())
 			r.Mount("/tags", tags.Tags{}.Routes())
 			r.Mount("/accounts", accountsCtl.Accounts{}.Routes())
+			r.Mount("/webhooks", webhooks.Webhooks{}.Routes())
 
 			//// 账号
 			//r.Mount("/account", user.Account{}.Routes())
@@ -179,3 +209,53 @@ func tryRead(fs embed.FS, prefix, requestedPath string, w http.ResponseWriter) e
 	_, err = io.Copy(w, f)
 	return err
 }
+
+// migrateStorage 把截图、缩略图和存档从一个存储复制到另一个存储, 目标已存在相同大小的文件时跳过.
+// 两个存储都使用配置文件中 [storage.local] 或 [storage.s3] 的设置, 迁移后修改 [storage] type 切换存储.
+func migrateStorage(args []string) int {
+	flags := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	from := flags.String("from", storage.TypeLocal, "源存储: local 或 s3")
+	to := flags.String("to", storage.TypeS3, "目标存储: local 或 s3")
+	deleteSource := flags.Bool("delete", false, "复制成功后删除源文件")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *from == *to {
+		log.Println("migrate-storage: -from 和 -to 不能相同")
+		return 2
+	}
+
+	src, err := storage.New(storage.LoadConfigType(*from))
+	if err != nil {
+		log.Println("migrate-storage", err)
+		return 1
+	}
+	dst, err := storage.New(storage.LoadConfigType(*to))
+	if err != nil {
+		log.Println("migrate-storage", err)
+		return 1
+	}
+
+	failed := 0
+	for _, prefix := range []string{consts.UploadDir + "/", consts.ArchiveDir + "/"} {
+		result, err := storage.Migrate(context.Background(), src, dst, prefix, *deleteSource, func(key string, err error) {
+			if err != nil {
+				failed++
+				log.Println("migrate-storage", key, err)
+				return
+			}
+			log.Println("migrate-storage", key)
+		})
+		if err != nil {
+			log.Println("migrate-storage", prefix, err)
+			return 1
+		}
+		log.Printf("migrate-storage %s: 复制 %d 个文件 (%d 字节), 跳过 %d 个\n", prefix, result.Copied, result.Bytes, result.Skipped)
+	}
+
+	if failed > 0 {
+		log.Printf("migrate-storage: %d 个文件失败\n", failed)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,13 @@
+package model
+
+// BookmarkContentModel is the archived readable content of a bookmark.
+type BookmarkContentModel struct {
+	DocId   int    `gorm:"column:docid;primaryKey"  db:"docid"        json:"docid"`
+	Title   string `gorm:"column:title"  db:"title"        json:"title"`
+	Content string `gorm:"column:content"  db:"content"      json:"content"`
+	HTML    string `gorm:"column:html"  db:"html"         json:"html"`
+}
+
+func (BookmarkContentModel) TableName() string {
+	return "bookmark_content"
+}
@@ -0,0 +1,23 @@
+package model
+
+// BookmarkHighlightModel is a text highlight on the archived content of a bookmark,
+// anchored with a text quote selector (exact, prefix, suffix) and a text position selector (start, end).
+// Positions count characters (unicode code points) of the snapshot content.
+type BookmarkHighlightModel struct {
+	ID         int    `gorm:"column:id;primaryKey"  db:"id"              json:"id"`
+	BookmarkId int    `gorm:"column:bookmark_id"  db:"bookmark_id"     json:"bookmarkId"`
+	SnapshotId int    `gorm:"column:snapshot_id"  db:"snapshot_id"     json:"snapshotId"`
+	Uid        int    `gorm:"column:uid"  db:"uid"             json:"uid"`
+	Exact      string `gorm:"column:exact"  db:"exact"           json:"exact"`
+	Prefix     string `gorm:"column:prefix"  db:"prefix"          json:"prefix"`
+	Suffix     string `gorm:"column:suffix"  db:"suffix"          json:"suffix"`
+	Start      int    `gorm:"column:position_start"  db:"position_start"  json:"start"` // -1 表示没有定位
+	End        int    `gorm:"column:position_end"  db:"position_end"    json:"end"`
+	Color      string `gorm:"column:color"  db:"color"           json:"color"`
+	Note       string `gorm:"column:note"  db:"note"            json:"note"` // Markdown
+	Created    string `gorm:"column:created"  db:"created"         json:"created"`
+}
+
+func (BookmarkHighlightModel) TableName() string {
+	return "bookmark_highlight"
+}
@@ -1,19 +1,71 @@
 package model
 
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// TimeLayout is the format of the time strings stored in the database, in local time.
+const TimeLayout = "2006-01-02 15:04:05"
+
+// 书签阅读状态
+const (
+	BookmarkStatusUnread   = "unread"
+	BookmarkStatusRead     = "read"
+	BookmarkStatusArchived = "archived"
+)
+
 // BookmarkModel is the record for an URL.
 type BookmarkModel struct {
-	ID         int        `gorm:"column:id"  db:"id"            json:"id"`
-	URL        string     `gorm:"column:url"  db:"url"           json:"url"`
-	Title      string     `gorm:"column:title"  db:"title"         json:"title"`
-	ImageURL   string     `gorm:"column:image_url"      db:"image_url"         json:"imageURL"`
-	Excerpt    string     `gorm:"column:excerpt"  db:"excerpt"       json:"excerpt"`
-	Uid        int        `gorm:"column:uid"  db:"uid"        json:"uid"`
-	Tags       string     `gorm:"column:tags"  db:"tags"        json:"tags"`
-	Public     int        `gorm:"column:public"  db:"public"        json:"public"`
-	Modified   string     `gorm:"column:modified"  db:"modified"      json:"modified"`
-	TagsDetail []TagModel `json:"tags_detail"  db:"-"     gorm:"-"`
+	ID          int        `gorm:"column:id"  db:"id"            json:"id"`
+	URL         string     `gorm:"column:url"  db:"url"           json:"url"`
+	Title       string     `gorm:"column:title"  db:"title"         json:"title"`
+	ImageURL    string     `gorm:"column:image_url"      db:"image_url"         json:"imageURL"`
+	Excerpt     string     `gorm:"column:excerpt"  db:"excerpt"       json:"excerpt"`
+	Uid         int        `gorm:"column:uid"  db:"uid"        json:"uid"`
+	Tags        string     `gorm:"column:tags;->"  db:"tags"        json:"tags"` // 标签 id, 逗号分隔, 由 bookmark_tag 的触发器维护
+	Public      int        `gorm:"column:public"  db:"public"        json:"public"`
+	Modified    string     `gorm:"column:modified"  db:"modified"      json:"modified"`
+	CreatedAt   int64      `gorm:"column:created_at"  db:"created_at"    json:"createdAt"`   // 添加时间, unix 时间戳
+	ModifiedAt  int64      `gorm:"column:modified_at"  db:"modified_at"   json:"modifiedAt"` // 与 Modified 一致的 unix 时间戳
+	VisitedAt   int64      `gorm:"column:visited_at"  db:"visited_at"    json:"visitedAt"`   // 最后打开时间, unix 时间戳
+	Author      string     `gorm:"column:author"  db:"author"        json:"author"`
+	Published   string     `gorm:"column:published"  db:"published"     json:"published"`
+	SiteName    string     `gorm:"column:site_name"  db:"site_name"     json:"siteName"`
+	Favicon     string     `gorm:"column:favicon"  db:"favicon"       json:"favicon"`
+	ContentType string     `gorm:"column:content_type"  db:"content_type"  json:"contentType"`
+	FileName    string     `gorm:"column:file_name"  db:"file_name"     json:"fileName"`
+	FileSize    int64      `gorm:"column:file_size"  db:"file_size"     json:"fileSize"`
+	Archive     string     `gorm:"column:archive"  db:"archive"       json:"archive"`
+	Warc        string     `gorm:"column:warc"  db:"warc"          json:"warc"`
+	Status      string     `gorm:"column:status;default:unread"  db:"status"  json:"status"` // unread, read, archived
+	Favorite    int        `gorm:"column:favorite"  db:"favorite"      json:"favorite"`
+	ReadAt      string     `gorm:"column:read_at"  db:"read_at"       json:"readAt"`
+	ArchivedAt  string     `gorm:"column:archived_at"  db:"archived_at"   json:"archivedAt"`
+	FavoritedAt string     `gorm:"column:favorited_at"  db:"favorited_at"  json:"favoritedAt"`
+	TagsDetail  []TagModel `json:"tags_detail"  db:"-"     gorm:"-"`
+	ImageSrc    string     `json:"imageSrc"  db:"-"     gorm:"-"` // 截图签名地址
 }
 
 func (BookmarkModel) TableName() string {
 	return "bookmark"
 }
+
+// BeforeSave keeps the timestamps in sync when the bookmark is saved with gorm.
+func (b *BookmarkModel) BeforeSave(tx *gorm.DB) error {
+	b.SyncTimestamps(time.Now())
+	return nil
+}
+
+// SyncTimestamps sets ModifiedAt from Modified, or both to now when Modified is empty or invalid,
+// and uses it as CreatedAt for new bookmarks.
+func (b *BookmarkModel) SyncTimestamps(now time.Time) {
+	if t, err := time.ParseInLocation(TimeLayout, b.Modified, time.Local); err == nil {
+		b.ModifiedAt = t.Unix()
+	} else {
+		b.Modified, b.ModifiedAt = now.Format(TimeLayout), now.Unix()
+	}
+	if b.CreatedAt <= 0 {
+		b.CreatedAt = b.ModifiedAt
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+// BookmarkNoteModel is a Markdown note attached to a bookmark.
+type BookmarkNoteModel struct {
+	ID         int    `gorm:"column:id;primaryKey"  db:"id"           json:"id"`
+	BookmarkId int    `gorm:"column:bookmark_id"  db:"bookmark_id"  json:"bookmarkId"`
+	Uid        int    `gorm:"column:uid"  db:"uid"          json:"uid"`
+	Content    string `gorm:"column:content"  db:"content"      json:"content"` // Markdown
+	Created    string `gorm:"column:created"  db:"created"      json:"created"`
+	Modified   string `gorm:"column:modified"  db:"modified"     json:"modified"`
+}
+
+func (BookmarkNoteModel) TableName() string {
+	return "bookmark_note"
+}
@@ -0,0 +1,23 @@
+package model
+
+// BookmarkSnapshotModel is one capture of a bookmark: metadata, content, screenshot and archive files.
+type BookmarkSnapshotModel struct {
+	ID          int    `gorm:"column:id;primaryKey"  db:"id"            json:"id"`
+	BookmarkId  int    `gorm:"column:bookmark_id"  db:"bookmark_id"   json:"bookmarkId"`
+	Created     string `gorm:"column:created"  db:"created"       json:"created"`
+	Title       string `gorm:"column:title"  db:"title"         json:"title"`
+	Excerpt     string `gorm:"column:excerpt"  db:"excerpt"       json:"excerpt"`
+	Author      string `gorm:"column:author"  db:"author"        json:"author"`
+	Published   string `gorm:"column:published"  db:"published"     json:"published"`
+	SiteName    string `gorm:"column:site_name"  db:"site_name"     json:"siteName"`
+	ContentType string `gorm:"column:content_type"  db:"content_type"  json:"contentType"`
+	ImageURL    string `gorm:"column:image_url"  db:"image_url"     json:"imageURL"`
+	Archive     string `gorm:"column:archive"  db:"archive"       json:"archive"`
+	Warc        string `gorm:"column:warc"  db:"warc"          json:"warc"`
+	Content     string `gorm:"column:content"  db:"content"       json:"content,omitempty"`
+	ImageSrc    string `gorm:"-"  db:"-"             json:"imageSrc"` // 截图签名地址
+}
+
+func (BookmarkSnapshotModel) TableName() string {
+	return "bookmark_snapshot"
+}
@@ -0,0 +1,18 @@
+package model
+
+// SavedSearchModel is a named search query of an account, shown as a smart collection next to the tags.
+type SavedSearchModel struct {
+	ID       int    `gorm:"column:id;primaryKey"  db:"id"        json:"id"`
+	Uid      int    `gorm:"column:uid"  db:"uid"       json:"uid"`
+	Name     string `gorm:"column:name"  db:"name"      json:"name"`
+	Query    string `gorm:"column:query"  db:"query"     json:"query"` // 搜索语句
+	Sort     string `gorm:"column:sort"  db:"sort"      json:"sort"`
+	Pinned   int    `gorm:"column:pinned"  db:"pinned"    json:"pinned"`
+	Shared   int    `gorm:"column:shared"  db:"shared"    json:"shared"` // 1 时其他账号可以看到和使用
+	Created  string `gorm:"column:created"  db:"created"   json:"created"`
+	Modified string `gorm:"column:modified"  db:"modified"  json:"modified"`
+}
+
+func (SavedSearchModel) TableName() string {
+	return "saved_search"
+}
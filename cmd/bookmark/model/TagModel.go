@@ -1,9 +1,10 @@
 package model
 
-// TagModel is the tag for a bookmark.
+// TagModel is the tag for a bookmark. Name is the full path of the tag, e.g. lang/go.
 type TagModel struct {
-	ID   int    `db:"id"          gorm:"column:id"          json:"id"`
-	Name string `db:"name"        gorm:"column:name"        json:"name"`
+	ID       int    `db:"id"          gorm:"column:id"          json:"id"`
+	Name     string `db:"name"        gorm:"column:name"        json:"name"`
+	ParentId int    `db:"parent_id"   gorm:"column:parent_id"   json:"parentId"`
 }
 
 func (TagModel) TableName() string {
@@ -0,0 +1,30 @@
+package model
+
+// 自动标签规则的匹配方式
+const (
+	TagRuleMatchGlob        = "glob"         // url 通配符, * 匹配任意字符, ? 匹配一个字符
+	TagRuleMatchRegex       = "regex"        // url 正则表达式
+	TagRuleMatchDomain      = "domain"       // 域名, 包括子域名
+	TagRuleMatchTitle       = "title"        // 标题包含关键字
+	TagRuleMatchContent     = "content"      // 摘要或正文包含关键字
+	TagRuleMatchContentType = "content_type" // 内容类型前缀, 例如 application/pdf、image/
+)
+
+// TagRuleModel is an auto-tagging rule of an account, applied to new bookmarks when they are added or imported.
+type TagRuleModel struct {
+	ID        int    `gorm:"column:id;primaryKey"  db:"id"          json:"id"`
+	Uid       int    `gorm:"column:uid"  db:"uid"         json:"uid"`
+	Name      string `gorm:"column:name"  db:"name"        json:"name"`
+	MatchType string `gorm:"column:match_type"  db:"match_type"  json:"matchType"`
+	Pattern   string `gorm:"column:pattern"  db:"pattern"     json:"pattern"`
+	Tags      string `gorm:"column:tags"  db:"tags"        json:"tags"`     // 添加的标签路径, 逗号分隔
+	Public    int    `gorm:"column:public"  db:"public"      json:"public"` // -1 不修改, 0 私有, 1 公开
+	Status    string `gorm:"column:status"  db:"status"      json:"status"` // 阅读状态, 为空时不修改
+	Enabled   int    `gorm:"column:enabled"  db:"enabled"     json:"enabled"`
+	Created   string `gorm:"column:created"  db:"created"     json:"created"`
+	Modified  string `gorm:"column:modified"  db:"modified"    json:"modified"`
+}
+
+func (TagRuleModel) TableName() string {
+	return "tag_rule"
+}
@@ -0,0 +1,66 @@
+package model
+
+// webhook 事件
+const (
+	WebhookEventBookmarkCreated = "bookmark.created"
+	WebhookEventBookmarkUpdated = "bookmark.updated"
+	WebhookEventBookmarkDeleted = "bookmark.deleted"
+	WebhookEventTagCreated      = "tag.created"
+	WebhookEventAccountCreated  = "account.created"
+	WebhookEventAccountUpdated  = "account.updated"
+	WebhookEventAccountDeleted  = "account.deleted"
+)
+
+// WebhookEvents 全部事件, 订阅 * 时接收全部事件
+var WebhookEvents = []string{
+	WebhookEventBookmarkCreated,
+	WebhookEventBookmarkUpdated,
+	WebhookEventBookmarkDeleted,
+	WebhookEventTagCreated,
+	WebhookEventAccountCreated,
+	WebhookEventAccountUpdated,
+	WebhookEventAccountDeleted,
+}
+
+// WebhookModel is an endpoint registered by an owner account, receiving HMAC signed JSON payloads of the selected events.
+type WebhookModel struct {
+	ID       int    `gorm:"column:id;primaryKey"  db:"id"        json:"id"`
+	Uid      int    `gorm:"column:uid"  db:"uid"       json:"uid"`
+	URL      string `gorm:"column:url"  db:"url"       json:"url"`
+	Secret   string `gorm:"column:secret"  db:"secret"    json:"secret"` // HMAC-SHA256 签名密钥
+	Events   string `gorm:"column:events"  db:"events"    json:"events"` // 订阅的事件, 逗号分隔
+	Enabled  int    `gorm:"column:enabled"  db:"enabled"   json:"enabled"`
+	Created  string `gorm:"column:created"  db:"created"   json:"created"`
+	Modified string `gorm:"column:modified"  db:"modified"  json:"modified"`
+}
+
+func (WebhookModel) TableName() string {
+	return "webhook"
+}
+
+// webhook 投递状态
+const (
+	WebhookDeliveryPending = "pending"
+	WebhookDeliverySuccess = "success"
+	WebhookDeliveryFailed  = "failed"
+)
+
+// WebhookDeliveryModel is a delivery of an event to a webhook, retried with backoff until it succeeds or runs out of attempts.
+type WebhookDeliveryModel struct {
+	ID            int    `gorm:"column:id;primaryKey"  db:"id"               json:"id"`
+	WebhookId     int    `gorm:"column:webhook_id"  db:"webhook_id"       json:"webhookId"`
+	Event         string `gorm:"column:event"  db:"event"            json:"event"`
+	Payload       string `gorm:"column:payload"  db:"payload"          json:"payload"`
+	Status        string `gorm:"column:status"  db:"status"           json:"status"`
+	Attempts      int    `gorm:"column:attempts"  db:"attempts"         json:"attempts"`
+	ResponseCode  int    `gorm:"column:response_code"  db:"response_code"    json:"responseCode"`
+	ResponseBody  string `gorm:"column:response_body"  db:"response_body"    json:"responseBody"`
+	Error         string `gorm:"column:error"  db:"error"            json:"error"`
+	NextAttemptAt int64  `gorm:"column:next_attempt_at"  db:"next_attempt_at"  json:"nextAttemptAt"` // 下次重试时间, 秒级时间戳
+	Created       string `gorm:"column:created"  db:"created"          json:"created"`
+	Modified      string `gorm:"column:modified"  db:"modified"         json:"modified"`
+}
+
+func (WebhookDeliveryModel) TableName() string {
+	return "webhook_delivery"
+}
@@ -50,6 +50,7 @@ export default {
     excerpt: String,
     public: Number,
     imageURL: String,
+    imageSrc: String,
     hasContent: Boolean,
     hasArchive: Boolean,
     hasEbook: Boolean,
@@ -91,6 +92,7 @@ export default {
     },
     thumbnailVisible() {
       return this.imageURL !== "" &&
+        !!this.imageSrc &&
         !this.hideThumbnail;
     },
     excerptVisible() {
@@ -99,9 +101,9 @@ export default {
         !this.hideExcerpt;
     },
     thumbnailStyleURL() {
-      // http://127.0.0.1:38112/api/bookmarks/showShot?image_url=1691566797806980000.jpg
+      // 列表返回带签名的地址 /api/bookmarks/1/image?exp=...&sig=...
       return {
-        backgroundImage: `url("/api/bookmarks/showShot?image_url=${this.imageURL}")`
+        backgroundImage: `url("${this.imageSrc}&w=640")`
       }
     },
     eventItem() {
@@ -0,0 +1,307 @@
+package archiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	nurl "net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"bookmark/pkg/fetch"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 单个资源超过该大小时不内联, 保留原地址
+const maxAssetSize = 5 << 20
+
+// @import 最多展开的层数
+const maxImportDepth = 3
+
+var (
+	rxCSSURL    = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+	rxCSSImport = regexp.MustCompile(`@import\s+(?:url\(\s*)?['"]?([^'")\s;]+)['"]?\s*\)?\s*([^;]*);`)
+)
+
+// 需要删除的元素, 存档页面不允许执行脚本或嵌入其他页面
+const removedElements = `script, iframe, frame, frameset, object, embed, applet, base,
+	link[rel~="preload"], link[rel~="modulepreload"], link[rel~="prefetch"], link[rel~="manifest"],
+	meta[http-equiv], meta[charset]`
+
+// Archiver 保存网页为单个 html 文件: 样式、图片和字体以 data URI 内联, 删除脚本
+type Archiver struct {
+	ctx     context.Context
+	pageURL *nurl.URL
+	cache   map[string]string // 资源地址 -> data URI
+}
+
+// Archive 生成网页的单文件存档, page 需要已经转换为 utf-8
+func Archive(ctx context.Context, pageURL string, page []byte) ([]byte, error) {
+	u, err := nurl.Parse(pageURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	a := &Archiver{
+		ctx:     ctx,
+		pageURL: u,
+		cache:   map[string]string{},
+	}
+	return a.archive(page)
+}
+
+func (a *Archiver) archive(page []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// noscript 中的内容直接展示, 需要先展开再删除脚本等元素, 否则其中的脚本会被保留
+	doc.Find("noscript").Each(func(i int, s *goquery.Selection) {
+		s.ReplaceWithHtml(s.Text())
+	})
+	doc.Find(removedElements).Remove()
+
+	// 删除事件属性和 javascript: 链接
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		for _, node := range s.Nodes {
+			removeUnsafeAttrs(node)
+		}
+	})
+
+	// 内联样式中的资源
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		setRawText(s, a.rewriteCSS(s.Text(), a.pageURL, 0))
+	})
+	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		s.SetAttr("style", a.rewriteCSS(s.AttrOr("style", ""), a.pageURL, 0))
+	})
+
+	// 外部样式表改为内联样式
+	doc.Find(`link[rel~="stylesheet"]`).Each(func(i int, s *goquery.Selection) {
+		href := a.resolve(a.pageURL, s.AttrOr("href", ""))
+		css, err := a.fetchCSS(href, 0)
+		if err != nil {
+			log.Println("archive stylesheet", href, err)
+			s.Remove()
+			return
+		}
+
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		if media := s.AttrOr("media", ""); media != "" {
+			style.Attr = []html.Attribute{{Key: "media", Val: media}}
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: css})
+		s.ReplaceWithNodes(style)
+	})
+
+	// 图片, 懒加载的图片使用真实地址
+	doc.Find("img, input[type=image]").Each(func(i int, s *goquery.Selection) {
+		src := firstNonEmpty(s.AttrOr("data-src", ""), s.AttrOr("data-original", ""), s.AttrOr("src", ""))
+		if src == "" {
+			src = firstSrcset(s.AttrOr("srcset", ""))
+		}
+		s.RemoveAttr("srcset")
+		s.RemoveAttr("sizes")
+		s.RemoveAttr("data-src")
+		s.RemoveAttr("data-original")
+		s.RemoveAttr("loading")
+		if src != "" {
+			s.SetAttr("src", a.inline(a.resolve(a.pageURL, src)))
+		}
+	})
+	doc.Find("picture source").Remove()
+	doc.Find("video[poster]").Each(func(i int, s *goquery.Selection) {
+		s.SetAttr("poster", a.inline(a.resolve(a.pageURL, s.AttrOr("poster", ""))))
+	})
+	doc.Find(`link[rel~="icon"]`).Each(func(i int, s *goquery.Selection) {
+		s.SetAttr("href", a.inline(a.resolve(a.pageURL, s.AttrOr("href", ""))))
+	})
+
+	// 其余链接改为绝对地址
+	for _, attr := range []string{"href", "src", "action"} {
+		doc.Find("[" + attr + "]").Each(func(i int, s *goquery.Selection) {
+			value := s.AttrOr(attr, "")
+			if !strings.HasPrefix(value, "data:") && !strings.HasPrefix(value, "#") {
+				s.SetAttr(attr, a.resolve(a.pageURL, value))
+			}
+		})
+	}
+
+	head := doc.Find("head")
+	head.PrependHtml(`<meta charset="utf-8">`)
+	head.AppendHtml(fmt.Sprintf(`<meta name="archive-source" content="%s">`, escapeAttr(a.pageURL.String())))
+
+	result, err := doc.Html()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return []byte(result), nil
+}
+
+// fetchCSS 下载样式表并内联其中的资源
+func (a *Archiver) fetchCSS(href string, depth int) (string, error) {
+	if href == "" {
+		return "", errors.New("empty stylesheet url")
+	}
+
+	resp, err := fetch.Get(a.ctx, href)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", errors.Errorf("status %d", resp.StatusCode)
+	}
+
+	base, err := nurl.Parse(resp.URL)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return a.rewriteCSS(string(resp.Body), base, depth), nil
+}
+
+// rewriteCSS 展开 @import, 并将 url() 中的图片和字体改为 data URI
+func (a *Archiver) rewriteCSS(css string, base *nurl.URL, depth int) string {
+	css = rxCSSImport.ReplaceAllStringFunc(css, func(match string) string {
+		parts := rxCSSImport.FindStringSubmatch(match)
+		if depth >= maxImportDepth {
+			return ""
+		}
+
+		imported, err := a.fetchCSS(a.resolve(base, parts[1]), depth+1)
+		if err != nil {
+			log.Println("archive @import", parts[1], err)
+			return ""
+		}
+		if media := strings.TrimSpace(parts[2]); media != "" {
+			return "@media " + media + " {" + imported + "}"
+		}
+		return imported
+	})
+
+	return rxCSSURL.ReplaceAllStringFunc(css, func(match string) string {
+		parts := rxCSSURL.FindStringSubmatch(match)
+		ref := strings.TrimSpace(parts[2])
+		if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return match
+		}
+		return `url("` + a.inline(a.resolve(base, ref)) + `")`
+	})
+}
+
+// inline 下载资源并转换为 data URI, 失败时返回原地址
+func (a *Archiver) inline(uri string) string {
+	if uri == "" || strings.HasPrefix(uri, "data:") {
+		return uri
+	}
+	if dataURI, ok := a.cache[uri]; ok {
+		return dataURI
+	}
+
+	dataURI := uri
+	resp, err := fetch.Get(a.ctx, uri)
+	switch {
+	case err != nil:
+		log.Println("archive asset", uri, err)
+	case resp.StatusCode >= http.StatusBadRequest:
+		log.Println("archive asset", uri, resp.StatusCode)
+	case len(resp.Body) > maxAssetSize:
+		log.Println("archive asset too large", uri, len(resp.Body))
+	default:
+		dataURI = "data:" + mediaType(resp) + ";base64," + base64.StdEncoding.EncodeToString(resp.Body)
+	}
+
+	a.cache[uri] = dataURI
+	return dataURI
+}
+
+// removeUnsafeAttrs 删除元素的事件属性和 javascript: 链接
+func removeUnsafeAttrs(node *html.Node) {
+	kept := node.Attr[:0]
+	for _, attr := range node.Attr {
+		if !unsafeAttr(attr) {
+			kept = append(kept, attr)
+		}
+	}
+	node.Attr = kept
+}
+
+// unsafeAttr 浏览器解析链接时忽略空白和控制字符, 判断前先去掉
+func unsafeAttr(attr html.Attribute) bool {
+	if strings.HasPrefix(strings.ToLower(attr.Key), "on") {
+		return true
+	}
+	value := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, attr.Val)
+	return strings.HasPrefix(strings.ToLower(value), "javascript:")
+}
+
+func (a *Archiver) resolve(base *nurl.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := nurl.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+func mediaType(resp *fetch.Response) string {
+	if t, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && t != "" && t != "application/octet-stream" {
+		return t
+	}
+	if u, err := nurl.Parse(resp.URL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return strings.Split(t, ";")[0]
+		}
+	}
+	return strings.Split(http.DetectContentType(resp.Body), ";")[0]
+}
+
+// firstSrcset 返回 srcset 中的第一个地址
+func firstSrcset(srcset string) string {
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// setRawText 设置 style 元素内容, SetText 会转义引号等字符
+func setRawText(s *goquery.Selection, text string) {
+	for _, node := range s.Nodes {
+		for node.FirstChild != nil {
+			node.RemoveChild(node.FirstChild)
+		}
+		node.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	}
+}
+
+func escapeAttr(s string) string {
+	return strings.NewReplacer(`&`, "&amp;", `"`, "&quot;", `<`, "&lt;", `>`, "&gt;").Replace(s)
+}
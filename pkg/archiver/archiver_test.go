@@ -0,0 +1,56 @@
+package archiver
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRemovesUnsafeAttrs(t *testing.T) {
+	page := `<html><head></head><body>
+<img onerror="x" src="data:image/gif;base64,R0lGOD" onload="z" alt="a">
+<a href="javascript:1" onclick="x" onmouseover="y" title="t">link</a>
+<a href=" java	script:alert(1)" ONFOCUS="x">tab</a>
+<div onclick="a" onkeydown="b" onkeyup="c" id="d">text</div>
+</body></html>`
+
+	out, err := Archive(context.Background(), "https://example.com/page", []byte(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := strings.ToLower(string(out))
+
+	for _, unsafe := range []string{"onerror", "onload", "onclick", "onmouseover", "onfocus", "onkeydown", "onkeyup", "javascript", "script:"} {
+		if strings.Contains(result, unsafe) {
+			t.Errorf("%s kept in %s", unsafe, result)
+		}
+	}
+	for _, safe := range []string{`alt="a"`, `title="t"`, `id="d"`, `src="data:image/gif;base64,r0lgod"`} {
+		if !strings.Contains(result, safe) {
+			t.Errorf("%s removed from %s", safe, result)
+		}
+	}
+}
+
+func TestArchiveUnwrapsNoscript(t *testing.T) {
+	page := `<html><head><noscript><style>p{color:red}</style></noscript></head><body>
+<noscript><script>alert(1)</script><iframe src="https://evil.example/"></iframe><img src="data:image/gif;base64,R0lGOD" onerror="x" alt="n"></noscript>
+</body></html>`
+
+	out, err := Archive(context.Background(), "https://example.com/page", []byte(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := strings.ToLower(string(out))
+
+	for _, unsafe := range []string{"<noscript", "<script", "alert(1)", "<iframe", "evil.example", "onerror"} {
+		if strings.Contains(result, unsafe) {
+			t.Errorf("%s kept in %s", unsafe, result)
+		}
+	}
+	for _, safe := range []string{`alt="n"`, "p{color:red}"} {
+		if !strings.Contains(result, safe) {
+			t.Errorf("%s removed from %s", safe, result)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBodyTooLarge 响应体超过 MaxBodySize
+var ErrBodyTooLarge = errors.New("fetch: response body too large")
+
+// Response 抓取结果
+type Response struct {
+	URL        string // 跳转之后的最终地址
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Client 对外抓取网页的 http 客户端
+type Client struct {
+	config Config
+	client *http.Client
+
+	// 配置的代理地址, 连接代理不受内网地址限制
+	proxyAddrs map[string]bool
+}
+
+// New 创建客户端
+func New(config Config) *Client {
+	c := &Client{
+		config:     config,
+		proxyAddrs: map[string]bool{},
+	}
+
+	proxies := []string{config.Proxy}
+	for _, proxy := range config.DomainProxies {
+		proxies = append(proxies, proxy)
+	}
+	for _, proxy := range proxies {
+		if u, err := parseProxy(proxy); err == nil && u != nil {
+			c.proxyAddrs[proxyAddr(u)] = true
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	guardedDialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   GuardControl,
+	}
+
+	transport := &http.Transport{
+		Proxy: c.proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if config.AllowPrivate || c.proxyAddrs[addr] {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			return guardedDialer.DialContext(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   config.ConnectTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		Jar:       newSiteJar(config.Cookies),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if recorder := recorderFrom(req.Context()); recorder != nil && req.Response != nil {
+				recorder(req.Response.Request, req.Response, nil)
+			}
+			if len(via) >= config.MaxRedirects {
+				return errors.Errorf("fetch: stopped after %d redirects", len(via))
+			}
+			return c.checkTarget(req.URL)
+		},
+	}
+
+	return c
+}
+
+// Get 抓取网页, 网络错误、429 和 5xx 会按配置退避重试
+func (c *Client) Get(ctx context.Context, uri string) (*Response, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := c.checkTarget(u); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.config.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := c.config.RetryBackoff * time.Duration(1<<uint(attempt-1))
+			select {
+			case <-ctx.Done():
+				return nil, errors.WithStack(ctx.Err())
+			case <-time.After(backoff):
+			}
+		}
+
+		resp, err := c.do(ctx, uri)
+		if err != nil {
+			if !retryable(err) {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) && attempt < c.config.Retries {
+			lastErr = errors.Errorf("fetch: %s returned %d", uri, resp.StatusCode)
+			continue
+		}
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+func (c *Client) do(ctx context.Context, uri string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8,ja;q=0.7")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if c.config.MaxBodySize > 0 && resp.ContentLength > c.config.MaxBodySize {
+		return nil, errors.Wrap(ErrBodyTooLarge, uri)
+	}
+
+	reader := io.Reader(resp.Body)
+	if c.config.MaxBodySize > 0 {
+		reader = io.LimitReader(resp.Body, c.config.MaxBodySize+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if c.config.MaxBodySize > 0 && int64(len(body)) > c.config.MaxBodySize {
+		return nil, errors.Wrap(ErrBodyTooLarge, uri)
+	}
+
+	if recorder := recorderFrom(ctx); recorder != nil {
+		recorder(resp.Request, resp, body)
+	}
+
+	return &Response{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// proxy 按域名选择代理. 通过代理访问时由代理解析域名, 所以在这里提前检查目标地址.
+// 检查之后代理会再解析一次, 两次结果可能不同 (DNS rebinding), 这里无法拦截;
+// 为了保留 TLS 的 SNI 和证书校验不改写成 IP, 需要由代理自身禁止访问内网.
+func (c *Client) proxy(req *http.Request) (*url.URL, error) {
+	raw := c.config.Proxy
+	if v, ok := lookupDomain(c.config.DomainProxies, req.URL.Hostname()); ok {
+		raw = v
+	}
+
+	u, err := parseProxy(raw)
+	if err != nil || u == nil {
+		return nil, err
+	}
+
+	if !c.config.AllowPrivate {
+		if err := checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// parseProxy 解析代理地址, 没有协议时按 socks5 处理 (兼容旧的 PROXYADDR)
+func parseProxy(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "direct" {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "socks5://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	default:
+		return nil, errors.Errorf("fetch: unsupported proxy scheme %q", u.Scheme)
+	}
+}
+
+// proxyAddr 返回连接代理时使用的 host:port
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// checkTarget 只允许 http 和 https, 并且不允许直接访问代理本身
+func (c *Client) checkTarget(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("fetch: unsupported scheme %q", u.Scheme)
+	}
+	if c.proxyAddrs[proxyAddr(u)] && !c.config.AllowPrivate {
+		return errors.Wrap(ErrBlockedAddress, u.Host)
+	}
+	return nil
+}
+
+func retryable(err error) bool {
+	return !errors.Is(err, ErrBlockedAddress) &&
+		!errors.Is(err, ErrBodyTooLarge) &&
+		!errors.Is(err, context.Canceled)
+}
+
+var defaultClient = New(DefaultConfig())
+
+// Init 使用配置初始化默认客户端
+func Init(config Config) {
+	defaultClient = New(config)
+}
+
+// Get 使用默认客户端抓取网页
+func Get(ctx context.Context, uri string) (*Response, error) {
+	return defaultClient.Get(ctx, uri)
+}
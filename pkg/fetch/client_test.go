@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// 测试用的公网地址, 只作为代理请求中的目标, 不会真正连接
+const publicHost = "93.184.216.34"
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.Retries = 0
+	return config
+}
+
+// newRedirectProxy 作为 http 代理, 把发往 publicHost 的请求重定向到 location
+func newRedirectProxy(t *testing.T, location string) *httptest.Server {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Hostname() != publicHost {
+			t.Errorf("proxy got request for %s", r.URL)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		http.Redirect(w, r, location, http.StatusFound)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func newTarget(t *testing.T, hits *int32) *httptest.Server {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Write([]byte("secret"))
+	}))
+	t.Cleanup(target.Close)
+	return target
+}
+
+func TestRedirectToLoopbackRefused(t *testing.T) {
+	var hits int32
+	target := newTarget(t, &hits)
+	proxy := newRedirectProxy(t, target.URL+"/secret")
+
+	// 重定向后直连, 由 GuardControl 拦截
+	direct := testConfig()
+	direct.DomainProxies = map[string]string{publicHost: proxy.URL}
+
+	// 重定向后仍然通过代理, 由 checkHost 拦截
+	proxied := testConfig()
+	proxied.Proxy = proxy.URL
+
+	for name, config := range map[string]Config{"direct": direct, "proxied": proxied} {
+		_, err := New(config).Get(context.Background(), "http://"+publicHost+"/start")
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("%s: redirect to loopback = %v, want ErrBlockedAddress", name, err)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("loopback target was requested %d times", hits)
+	}
+}
+
+func TestDirectLoopbackRefused(t *testing.T) {
+	var hits int32
+	target := newTarget(t, &hits)
+
+	if _, err := New(testConfig()).Get(context.Background(), target.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("direct loopback = %v, want ErrBlockedAddress", err)
+	}
+
+	config := testConfig()
+	config.AllowPrivate = true
+	resp, err := New(config).Get(context.Background(), target.URL)
+	if err != nil || string(resp.Body) != "secret" {
+		t.Errorf("allow_private = %v, %v", resp, err)
+	}
+	if hits != 1 {
+		t.Errorf("target hits = %d, want 1", hits)
+	}
+}
+
+func TestProxyAddressRefused(t *testing.T) {
+	var hits int32
+	proxy := newTarget(t, &hits)
+
+	config := testConfig()
+	config.Proxy = proxy.URL
+	config.DomainProxies = map[string]string{"example.com": "10.0.0.1"} // 没有协议按 socks5, 默认端口 1080
+	client := New(config)
+
+	for _, uri := range []string{proxy.URL + "/", "http://10.0.0.1:1080/", "https://10.0.0.1:1080/"} {
+		if _, err := client.Get(context.Background(), uri); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Get(%s) = %v, want ErrBlockedAddress", uri, err)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("proxy was requested directly %d times", hits)
+	}
+
+	for _, uri := range []string{"file:///etc/passwd", "ftp://" + publicHost + "/", "gopher://" + publicHost + "/"} {
+		if _, err := client.Get(context.Background(), uri); err == nil {
+			t.Errorf("Get(%s) should fail", uri)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config 抓取配置
+type Config struct {
+	UserAgent      string
+	Timeout        time.Duration // 单次请求超时
+	ConnectTimeout time.Duration // 建立连接超时
+	MaxBodySize    int64         // 响应体最大字节数
+	MaxRedirects   int
+	Retries        int           // 失败重试次数
+	RetryBackoff   time.Duration // 首次重试等待时间, 之后每次翻倍
+
+	// 默认代理, 支持 http:// https:// socks5://
+	Proxy string
+	// 按域名选择代理, 包含子域名, 值为 direct 时直连
+	DomainProxies map[string]string
+	// 按域名设置的初始 cookie, 例如 "a=1; b=2", 这些站点会启用 cookie jar
+	Cookies map[string]string
+
+	// 允许访问内网、回环和链路本地地址, 仅用于本机调试
+	AllowPrivate bool
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36",
+		Timeout:        time.Second * 10,
+		ConnectTimeout: time.Second * 5,
+		MaxBodySize:    20 << 20,
+		MaxRedirects:   10,
+		Retries:        2,
+		RetryBackoff:   time.Millisecond * 500,
+	}
+}
+
+// LoadConfig 读取配置文件中的 [fetch] 配置, 未设置的使用默认值.
+// 兼容旧的 PROXYADDR 环境变量作为默认代理.
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if v := viper.GetString("fetch.user_agent"); v != "" {
+		config.UserAgent = v
+	}
+	if v := viper.GetDuration("fetch.timeout"); v > 0 {
+		config.Timeout = v
+	}
+	if v := viper.GetDuration("fetch.connect_timeout"); v > 0 {
+		config.ConnectTimeout = v
+	}
+	if v := viper.GetInt64("fetch.max_body_size"); v > 0 {
+		config.MaxBodySize = v
+	}
+	if v := viper.GetInt("fetch.max_redirects"); v > 0 {
+		config.MaxRedirects = v
+	}
+	if viper.IsSet("fetch.retries") {
+		config.Retries = viper.GetInt("fetch.retries")
+	}
+	if v := viper.GetDuration("fetch.retry_backoff"); v > 0 {
+		config.RetryBackoff = v
+	}
+
+	config.Proxy = viper.GetString("fetch.proxy")
+	if config.Proxy == "" {
+		config.Proxy = os.Getenv("PROXYADDR")
+	}
+
+	config.DomainProxies = lowerKeys(viper.GetStringMapString("fetch.domain_proxies"))
+	config.Cookies = lowerKeys(viper.GetStringMapString("fetch.cookies"))
+	config.AllowPrivate = viper.GetBool("fetch.allow_private")
+
+	return config
+}
+
+func lowerKeys(m map[string]string) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[strings.ToLower(k)] = v
+	}
+	return result
+}
+
+// matchDomain 判断 host 是否为 domain 或其子域名
+func matchDomain(host, domain string) bool {
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// lookupDomain 返回最长匹配的域名配置
+func lookupDomain(m map[string]string, host string) (string, bool) {
+	best := ""
+	found := false
+	for domain := range m {
+		if matchDomain(host, domain) && len(domain) >= len(best) {
+			best, found = domain, true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	return m[best], true
+}
@@ -0,0 +1,90 @@
+package fetch
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBlockedAddress 目标地址属于内网、回环或链路本地地址
+var ErrBlockedAddress = errors.New("fetch: blocked address")
+
+// 禁止访问的地址段, 补充 netip 未覆盖的保留地址
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// IsBlockedIP 判断地址是否属于内网、回环、链路本地、组播或保留地址
+func IsBlockedIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GuardControl 在 DNS 解析之后、建立连接之前检查实际连接的地址,
+// 可以防止 DNS rebinding 以及重定向到内网地址.
+func GuardControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if IsBlockedIP(addr) {
+		return errors.Wrap(ErrBlockedAddress, address)
+	}
+	return nil
+}
+
+// checkHost 解析域名并检查所有地址, 用于通过代理访问的请求 (由代理负责解析域名)
+func checkHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if IsBlockedIP(addr) {
+			return errors.Wrap(ErrBlockedAddress, host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, addr := range addrs {
+		if IsBlockedIP(addr) {
+			return errors.Wrap(ErrBlockedAddress, host+" ("+addr.String()+")")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"127.1.2.3", true},
+		{"::1", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"ff02::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::7f00:1", true},
+		{"64:ff9b::5db8:d822", true},
+		{"2001:db8::1", true},
+		{"93.184.216.34", false},
+		{"8.8.8.8", false},
+		{"::ffff:8.8.8.8", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := IsBlockedIP(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+			t.Errorf("IsBlockedIP(%s) = %v, want %v", tt.addr, got, tt.blocked)
+		}
+	}
+}
+
+func TestGuardControl(t *testing.T) {
+	tests := []struct {
+		address string
+		blocked bool
+	}{
+		{"127.0.0.1:80", true},
+		{"[::1]:443", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"169.254.169.254:80", true},
+		{"93.184.216.34:443", false},
+		{"[2606:4700:4700::1111]:443", false},
+	}
+	for _, tt := range tests {
+		err := GuardControl("tcp", tt.address, nil)
+		if blocked := errors.Is(err, ErrBlockedAddress); blocked != tt.blocked || (!tt.blocked && err != nil) {
+			t.Errorf("GuardControl(%s) = %v, want blocked %v", tt.address, err, tt.blocked)
+		}
+	}
+
+	if err := GuardControl("tcp", "example.com:80", nil); err == nil {
+		t.Error("GuardControl should only accept resolved addresses")
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	for host, blocked := range map[string]bool{
+		"127.0.0.1":     true,
+		"10.1.2.3":      true,
+		"::ffff:7f00:1": true,
+		"localhost":     true,
+		"93.184.216.34": false,
+	} {
+		err := checkHost(ctx, host)
+		if errors.Is(err, ErrBlockedAddress) != blocked || (!blocked && err != nil) {
+			t.Errorf("checkHost(%s) = %v, want blocked %v", host, err, blocked)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// siteJar 只为配置过的站点保存和发送 cookie, 每个站点使用独立的 cookie jar
+type siteJar struct {
+	jars map[string]*cookiejar.Jar
+}
+
+func newSiteJar(cookies map[string]string) *siteJar {
+	jar := &siteJar{jars: map[string]*cookiejar.Jar{}}
+
+	for domain, raw := range cookies {
+		domainJar, _ := cookiejar.New(nil)
+		jar.jars[domain] = domainJar
+
+		// 初始 cookie
+		header := http.Header{"Cookie": []string{raw}}
+		initial := (&http.Request{Header: header}).Cookies()
+		for _, c := range initial {
+			c.Domain = domain
+			c.Path = "/"
+		}
+		for _, scheme := range []string{"http", "https"} {
+			domainJar.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: "/"}, initial)
+		}
+	}
+
+	return jar
+}
+
+func (j *siteJar) jar(u *url.URL) *cookiejar.Jar {
+	host := strings.ToLower(u.Hostname())
+	best := ""
+	for domain := range j.jars {
+		if matchDomain(host, domain) && len(domain) > len(best) {
+			best = domain
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return j.jars[best]
+}
+
+func (j *siteJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if jar := j.jar(u); jar != nil {
+		jar.SetCookies(u, cookies)
+	}
+}
+
+func (j *siteJar) Cookies(u *url.URL) []*http.Cookie {
+	if jar := j.jar(u); jar != nil {
+		return jar.Cookies(u)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+)
+
+// Recorder 记录抓取时的每一次请求和响应 (包括跳转), body 为已经解压的响应体, 跳转响应的 body 为空
+type Recorder func(req *http.Request, resp *http.Response, body []byte)
+
+type recorderKey struct{}
+
+// WithRecorder 在 ctx 上附加 Recorder, 使用该 ctx 的抓取都会被记录
+func WithRecorder(ctx context.Context, recorder Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, recorder)
+}
+
+func recorderFrom(ctx context.Context) Recorder {
+	recorder, _ := ctx.Value(recorderKey{}).(Recorder)
+	return recorder
+}
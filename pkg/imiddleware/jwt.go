@@ -22,6 +22,13 @@ const JWTSECTET = "https://github.com/cute-angelia/shiori"
 */
 func setHeaderInfo(r *http.Request, jwtToken string) {
 
+	// 删除客户端传入的 jwt_ 头, 只使用 token 中的信息
+	for key := range r.Header {
+		if strings.HasPrefix(strings.ToLower(key), "jwt_") {
+			r.Header.Del(key)
+		}
+	}
+
 	// 设置 cid
 	cid := r.URL.Query().Get("cid")
 	r.Header.Set("jwt_cid", fmt.Sprintf("%v", cid))
@@ -101,8 +108,8 @@ func Jwt(allowList []string) func(http.Handler) http.Handler {
 
 			// 例外直接过
 			for _, v := range allowLoginPaths {
-				// 正则匹配
-				if strings.Contains(v, "*") {
+				// 正则匹配, 包含 * 或以 ^ 开头
+				if strings.Contains(v, "*") || strings.HasPrefix(v, "^") {
 					r1 := regexp.MustCompile(v)
 					if r1.MatchString(r.URL.Path) {
 						// log.Println("例外匹配过滤JWT √：", r1.MatchString(r.URL.Path), v, r.URL.Path)
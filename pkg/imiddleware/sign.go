@@ -1,6 +1,9 @@
 package imiddleware
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/cute-angelia/go-utils/components/caches/ibunt"
 	"github.com/cute-angelia/go-utils/third_party/wechat"
@@ -72,6 +75,15 @@ func SignPass(allowList []string, apiKey string) func(http.Handler) http.Handler
 	}
 }
 
+// HmacSign 对外发送请求 (如 webhook) 的签名, 和 SignPass 一样使用双方共享的密钥.
+// 签名内容为 "时间戳.请求体", 结果为 hex 编码的 HMAC-SHA256, 接收方按同样方式计算后比较
+func HmacSign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func SignPre(allowList []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
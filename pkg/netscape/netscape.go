@@ -0,0 +1,224 @@
+package netscape
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	nethtml "golang.org/x/net/html"
+)
+
+// 浏览器导出的书签文件 (Netscape Bookmark File Format)
+
+// Bookmark 书签文件中的一条书签
+type Bookmark struct {
+	URL          string
+	Title        string
+	Folder       []string // 所在文件夹, 从最外层开始
+	Tags         []string // TAGS 属性
+	AddDate      time.Time
+	LastModified time.Time
+	Description  string
+	Private      bool
+}
+
+// Parse 解析书签文件, 按出现顺序返回书签
+func Parse(r io.Reader) ([]Bookmark, error) {
+	var (
+		list    []Bookmark
+		folders []string // 已打开的 DL 对应的文件夹, 最外层为空
+		pending string   // H3 之后的 DL 属于该文件夹
+		text    strings.Builder
+		inH3    bool
+		inA     bool
+		inDD    bool
+		last    = -1 // DD 描述属于紧挨着的书签
+	)
+
+	z := nethtml.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case nethtml.ErrorToken:
+			if z.Err() == io.EOF {
+				return list, nil
+			}
+			return list, errors.WithStack(z.Err())
+
+		case nethtml.TextToken:
+			if inH3 || inA {
+				text.Write(z.Text())
+			} else if inDD && last >= 0 {
+				list[last].Description += string(z.Text())
+			}
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if tag := string(name); tag != "dd" && tag != "p" {
+				if inDD && last >= 0 {
+					list[last].Description = strings.TrimSpace(list[last].Description)
+				}
+				inDD = false
+			}
+
+			switch string(name) {
+			case "h3":
+				inH3, last = true, -1
+				text.Reset()
+			case "dl":
+				folders = append(folders, pending)
+				pending, last = "", -1
+			case "dd":
+				inDD = true
+			case "a":
+				bookmark := Bookmark{Folder: folderPath(folders)}
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = z.TagAttr()
+					switch string(key) {
+					case "href":
+						bookmark.URL = strings.TrimSpace(string(value))
+					case "add_date":
+						bookmark.AddDate = parseUnix(string(value))
+					case "last_modified":
+						bookmark.LastModified = parseUnix(string(value))
+					case "tags":
+						for _, tag := range strings.Split(string(value), ",") {
+							if tag = strings.TrimSpace(tag); len(tag) > 0 {
+								bookmark.Tags = append(bookmark.Tags, tag)
+							}
+						}
+					case "private":
+						bookmark.Private = string(value) == "1"
+					}
+				}
+				list = append(list, bookmark)
+				inA, last = true, len(list)-1
+				text.Reset()
+			}
+
+		case nethtml.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "h3":
+				inH3 = false
+				pending = strings.TrimSpace(text.String())
+			case "a":
+				if inA {
+					list[last].Title = strings.TrimSpace(text.String())
+				}
+				inA = false
+			case "dl":
+				if inDD && last >= 0 {
+					list[last].Description = strings.TrimSpace(list[last].Description)
+				}
+				inDD, last = false, -1
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			}
+		}
+	}
+}
+
+// parseUnix 解析秒级时间戳, 无效时返回零值
+func parseUnix(s string) time.Time {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil && sec > 0 {
+		return time.Unix(sec, 0)
+	}
+	return time.Time{}
+}
+
+// folderPath 去掉最外层和没有名字的文件夹
+func folderPath(folders []string) []string {
+	path := []string{}
+	for _, folder := range folders {
+		if len(folder) > 0 {
+			path = append(path, folder)
+		}
+	}
+	return path
+}
+
+// folderNode 导出时的文件夹
+type folderNode struct {
+	name      string
+	children  map[string]*folderNode
+	bookmarks []Bookmark
+}
+
+func newFolderNode(name string) *folderNode {
+	return &folderNode{name: name, children: map[string]*folderNode{}}
+}
+
+// Write 写入书签文件, 书签按 Folder 放到对应的文件夹中, 文件夹按名字排序
+func Write(w io.Writer, title string, bookmarks []Bookmark) error {
+	root := newFolderNode(title)
+	for _, bookmark := range bookmarks {
+		node := root
+		for _, name := range bookmark.Folder {
+			child, ok := node.children[name]
+			if !ok {
+				child = newFolderNode(name)
+				node.children[name] = child
+			}
+			node = child
+		}
+		node.bookmarks = append(node.bookmarks, bookmark)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>%s</TITLE>
+<H1>%s</H1>
+`, html.EscapeString(title), html.EscapeString(title))
+	writeFolder(bw, root, 0)
+	return errors.WithStack(bw.Flush())
+}
+
+func writeFolder(w *bufio.Writer, node *folderNode, depth int) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(w, "%s<DL><p>\n", indent)
+
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s    <DT><H3>%s</H3>\n", indent, html.EscapeString(name))
+		writeFolder(w, node.children[name], depth+1)
+	}
+
+	for _, bookmark := range node.bookmarks {
+		fmt.Fprintf(w, `%s    <DT><A HREF="%s"`, indent, html.EscapeString(bookmark.URL))
+		if !bookmark.AddDate.IsZero() {
+			fmt.Fprintf(w, ` ADD_DATE="%d"`, bookmark.AddDate.Unix())
+		}
+		if !bookmark.LastModified.IsZero() {
+			fmt.Fprintf(w, ` LAST_MODIFIED="%d"`, bookmark.LastModified.Unix())
+		}
+		if bookmark.Private {
+			fmt.Fprint(w, ` PRIVATE="1"`)
+		}
+		if len(bookmark.Tags) > 0 {
+			fmt.Fprintf(w, ` TAGS="%s"`, html.EscapeString(strings.Join(bookmark.Tags, ",")))
+		}
+		fmt.Fprintf(w, ">%s</A>\n", html.EscapeString(bookmark.Title))
+		if len(bookmark.Description) > 0 {
+			fmt.Fprintf(w, "%s    <DD>%s\n", indent, html.EscapeString(bookmark.Description))
+		}
+	}
+
+	fmt.Fprintf(w, "%s</DL><p>\n", indent)
+}
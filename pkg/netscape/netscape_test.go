@@ -0,0 +1,118 @@
+package netscape
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFirefoxExport(t *testing.T) {
+	f, err := os.Open("testdata/firefox.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	list, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Bookmark{
+		{
+			URL:          "https://www.mozilla.org/en-US/firefox/central/",
+			Title:        "Getting Started",
+			Folder:       []string{},
+			AddDate:      time.Unix(1700000000, 0),
+			LastModified: time.Unix(1700000100, 0),
+		},
+		{
+			URL:          "https://go.dev/doc/effective_go",
+			Title:        "Effective Go",
+			Folder:       []string{"Dev & Ops"},
+			Tags:         []string{"go", "docs"},
+			AddDate:      time.Unix(1700000400, 0),
+			LastModified: time.Unix(1700000500, 0),
+			Description:  "Tips for writing clear, idiomatic Go code & more",
+		},
+		{
+			URL:     "https://www.sqlite.org/lang_with.html?a=1&b=2",
+			Title:   "The WITH Clause – SQLite",
+			Folder:  []string{"Dev & Ops", "Databases"},
+			AddDate: time.Unix(1700000800, 0),
+		},
+		{
+			URL:          "https://example.com/post",
+			Title:        `<Tom> & Jerry's "notes"`,
+			Folder:       []string{"Dev & Ops"},
+			AddDate:      time.Unix(1700000900, 0),
+			LastModified: time.Unix(1700001000, 0),
+		},
+		{
+			URL:          "https://news.ycombinator.com/",
+			Title:        "Hacker News",
+			Folder:       []string{"Bookmarks Toolbar"},
+			AddDate:      time.Unix(1700001300, 0),
+			LastModified: time.Unix(1700001400, 0),
+		},
+		{
+			URL:     "place:parent=menu________&sort=12",
+			Title:   "Recently Bookmarked",
+			Folder:  []string{},
+			AddDate: time.Unix(1700001700, 0),
+		},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", list, want)
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	// Write 先写子文件夹再写书签, 这里按写出的顺序排列, 解析后顺序相同
+	bookmarks := []Bookmark{
+		{
+			URL:          "https://example.com/a?x=1&y=2",
+			Title:        `<b>Tom</b> & Jerry's "quotes"`,
+			Folder:       []string{"Dev", "Go"},
+			Tags:         []string{"go", "lang/go", "a&b"},
+			AddDate:      time.Unix(1600000000, 0),
+			LastModified: time.Unix(1600000100, 0),
+			Description:  "Nested <folder> & description",
+			Private:      true,
+		},
+		{
+			URL:     "https://example.com/b",
+			Title:   "Dev root",
+			Folder:  []string{"Dev"},
+			AddDate: time.Unix(1600000200, 0),
+		},
+		{
+			URL:         "https://example.com/c",
+			Title:       "中文标题",
+			Folder:      []string{"Read & Later"},
+			Description: "第一行 描述",
+		},
+		{
+			URL:     "https://example.com/d",
+			Title:   "Top level",
+			Folder:  []string{},
+			Tags:    []string{"top"},
+			Private: true,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Bookmarks & more", bookmarks); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, bookmarks) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", list, bookmarks)
+	}
+}
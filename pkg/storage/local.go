@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LocalConfig 本地存储配置
+type LocalConfig struct {
+	Dir string // 根目录
+}
+
+// Local 本地磁盘存储, key 对应根目录下的相对路径
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put 先写临时文件再重命名, 避免读取到写了一半的文件
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), p))
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+
+	p, _ := l.path(key)
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, info, localError(err)
+	}
+	return file, info, nil
+}
+
+// Delete 文件不存在时不返回错误
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// List 列出 key 以 prefix 开头的文件, 按 key 排序, 不包括未完成的临时文件
+func (l *Local) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// 从 prefix 所在的目录开始遍历
+	dir := l.root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		sub, err := CleanKey(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(l.root, filepath.FromSlash(sub))
+	}
+
+	var list []ObjectInfo
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		list = append(list, ObjectInfo{
+			Key:         key,
+			Size:        stat.Size(),
+			ModTime:     stat.ModTime(),
+			ContentType: contentTypeByKey(key),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	stat, err := os.Stat(p)
+	if err != nil {
+		return ObjectInfo{}, localError(err)
+	}
+	if stat.IsDir() {
+		return ObjectInfo{}, errors.Wrap(ErrNotFound, key)
+	}
+
+	key, _ = CleanKey(key)
+	return ObjectInfo{
+		Key:         key,
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+		ContentType: contentTypeByKey(key),
+	}, nil
+}
+
+func localError(err error) error {
+	if os.IsNotExist(err) {
+		return errors.Wrap(ErrNotFound, err.Error())
+	}
+	return errors.WithStack(err)
+}
+
+// contentTypeByKey 本地文件不保存类型, 按扩展名判断
+func contentTypeByKey(key string) string {
+	name := strings.TrimSuffix(key, ".gz")
+	if strings.HasSuffix(name, ".warc") {
+		return "application/warc"
+	}
+	if contentType := mime.TypeByExtension(path.Ext(key)); len(contentType) > 0 {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("storage: not found")
+
+// ErrInvalidKey 文件名为空或包含 .. 等路径
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// 存储类型
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+// ObjectInfo 文件信息
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Storage 文件存储, key 为使用 / 分隔的相对路径, 例如 uploads/xxx.png
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+}
+
+// Config 存储配置
+type Config struct {
+	Type  string // local 或 s3
+	Local LocalConfig
+	S3    S3Config
+}
+
+// LoadConfig 读取配置文件中的 [storage] 配置, 默认使用本地 data 目录
+func LoadConfig() Config {
+	return LoadConfigType(viper.GetString("storage.type"))
+}
+
+// LoadConfigType 按指定类型读取配置, 用于在不同存储之间迁移文件
+func LoadConfigType(storageType string) Config {
+	config := Config{
+		Type: strings.ToLower(storageType),
+		Local: LocalConfig{
+			Dir: viper.GetString("storage.local.dir"),
+		},
+		S3: S3Config{
+			Endpoint:  viper.GetString("storage.s3.endpoint"),
+			Region:    viper.GetString("storage.s3.region"),
+			Bucket:    viper.GetString("storage.s3.bucket"),
+			AccessKey: viper.GetString("storage.s3.access_key"),
+			SecretKey: viper.GetString("storage.s3.secret_key"),
+			PathStyle: viper.GetBool("storage.s3.path_style"),
+			Prefix:    viper.GetString("storage.s3.prefix"),
+		},
+	}
+	if config.Type == "" {
+		config.Type = TypeLocal
+	}
+	if config.Local.Dir == "" {
+		config.Local.Dir = "data"
+	}
+	return config
+}
+
+// New 按配置创建存储
+func New(config Config) (Storage, error) {
+	switch config.Type {
+	case TypeLocal:
+		return NewLocal(config.Local.Dir), nil
+	case TypeS3:
+		return NewS3(config.S3)
+	default:
+		return nil, errors.Errorf("storage: unsupported type %q", config.Type)
+	}
+}
+
+var defaultStorage Storage = NewLocal("data")
+
+// Init 设置默认存储
+func Init(s Storage) {
+	defaultStorage = s
+}
+
+// Default 默认存储
+func Default() Storage {
+	return defaultStorage
+}
+
+// CleanKey 检查并规范化 key, 不允许绝对路径和 ..
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, `\`, "/")
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", errors.Wrap(ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", errors.Wrap(ErrInvalidKey, key)
+		}
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." {
+		return "", errors.Wrap(ErrInvalidKey, key)
+	}
+	return cleaned, nil
+}
+
+// PutBytes 保存数据
+func PutBytes(ctx context.Context, s Storage, key string, data []byte, contentType string) error {
+	return s.Put(ctx, key, bytes.NewReader(data), contentType)
+}
+
+// ReadAll 读取整个文件
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, ObjectInfo, error) {
+	body, info, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, info, errors.WithStack(err)
+	}
+	return data, info, nil
+}
+
+// Exists 文件是否存在
+func Exists(ctx context.Context, s Storage, key string) bool {
+	_, err := s.Stat(ctx, key)
+	return err == nil
+}
+
+// MigrateResult 迁移结果
+type MigrateResult struct {
+	Copied  int
+	Skipped int // 目标已存在并且大小相同
+	Bytes   int64
+}
+
+// Migrate 把 prefix 下的文件从 from 复制到 to, deleteSource 为 true 时复制成功后删除源文件
+func Migrate(ctx context.Context, from, to Storage, prefix string, deleteSource bool, progress func(key string, err error)) (MigrateResult, error) {
+	result := MigrateResult{}
+
+	objects, err := from.List(ctx, prefix)
+	if err != nil {
+		return result, err
+	}
+
+	for _, object := range objects {
+		if target, err := to.Stat(ctx, object.Key); err == nil && target.Size == object.Size {
+			result.Skipped++
+		} else {
+			err := copyObject(ctx, from, to, object)
+			if progress != nil {
+				progress(object.Key, err)
+			}
+			if err != nil {
+				continue
+			}
+			result.Copied++
+			result.Bytes += object.Size
+		}
+
+		if deleteSource {
+			if err := from.Delete(ctx, object.Key); err != nil && progress != nil {
+				progress(object.Key, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func copyObject(ctx context.Context, from, to Storage, object ObjectInfo) error {
+	body, info, err := from.Get(ctx, object.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return to.Put(ctx, object.Key, body, info.ContentType)
+}
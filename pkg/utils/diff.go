@@ -0,0 +1,148 @@
+package utils
+
+import "strings"
+
+// 差异类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// 最多计算的编辑步数, 超过后剩余部分按整体删除再插入处理
+const diffMaxEdits = 1000
+
+// DiffChunk 连续相同类型的行
+type DiffChunk struct {
+	Type  string   `json:"type"`
+	Lines []string `json:"lines"`
+}
+
+// DiffLines 按行比较两段文本 (Myers 算法)
+func DiffLines(a, b string) []DiffChunk {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	// 去掉相同的开头和结尾, 减少计算量
+	prefix := 0
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix &&
+		linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+
+	var chunks []DiffChunk
+	add := func(typ, line string) {
+		if n := len(chunks); n > 0 && chunks[n-1].Type == typ {
+			chunks[n-1].Lines = append(chunks[n-1].Lines, line)
+			return
+		}
+		chunks = append(chunks, DiffChunk{Type: typ, Lines: []string{line}})
+	}
+
+	for _, line := range linesA[:prefix] {
+		add(DiffEqual, line)
+	}
+
+	middleA := linesA[prefix : len(linesA)-suffix]
+	middleB := linesB[prefix : len(linesB)-suffix]
+	if ops, ok := myers(middleA, middleB); ok {
+		for _, op := range ops {
+			add(op.Type, op.Lines[0])
+		}
+	} else {
+		for _, line := range middleA {
+			add(DiffDelete, line)
+		}
+		for _, line := range middleB {
+			add(DiffInsert, line)
+		}
+	}
+
+	for _, line := range linesA[len(linesA)-suffix:] {
+		add(DiffEqual, line)
+	}
+	return chunks
+}
+
+// myers 返回逐行的编辑操作, 编辑步数超过 diffMaxEdits 时返回 false
+func myers(a, b []string) ([]DiffChunk, bool) {
+	n, m := len(a), len(b)
+	offset := diffMaxEdits + 1
+	v := make([]int, 2*offset+1)
+
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && d <= diffMaxEdits && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// 回溯编辑路径
+	var ops []DiffChunk
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, DiffChunk{Type: DiffEqual, Lines: []string{a[x-1]}})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, DiffChunk{Type: DiffInsert, Lines: []string{b[y-1]}})
+			y--
+		} else {
+			ops = append(ops, DiffChunk{Type: DiffDelete, Lines: []string{a[x-1]}})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, DiffChunk{Type: DiffEqual, Lines: []string{a[x-1]}})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
+
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
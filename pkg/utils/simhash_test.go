@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World! a 42", []string{"hello", "world", "42"}},
+		{"书签管理", []string{"书签", "签管", "管理"}},
+		{"Go 语言 x", []string{"go", "语言"}},
+		{"字", []string{"字"}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	article := `The quick brown fox jumps over the lazy dog while the farmer watches from the porch.
+		Later that evening the fox returns to the barn looking for chickens, but the dog is awake
+		and chases it back into the forest where it hides until the morning sun rises over the hills.
+		The farmer decides to build a taller fence around the barn to keep the chickens safe next time.`
+	nearDuplicate := `The quick brown fox jumps over the lazy dog while the farmer watches from the porch.
+		Later that evening the fox returns to the barn looking for chickens, but the dog is awake
+		and chases it back into the woods where it hides until the morning sun rises over the hills.
+		The farmer decides to build a taller fence around the barn to keep the chickens safe next time.`
+	different := `Go is an open source programming language that makes it simple to build secure,
+		scalable systems. It has garbage collection, structural typing and concurrency primitives
+		such as goroutines and channels, and compiles quickly to a single static binary.`
+
+	if SimHash(article) != SimHash(article) {
+		t.Error("SimHash is not deterministic")
+	}
+	if d := HammingDistance(SimHash(article), SimHash(nearDuplicate)); d > 3 {
+		t.Errorf("near duplicate distance = %d, want <= 3", d)
+	}
+	if d := HammingDistance(SimHash(article), SimHash(different)); d <= 10 {
+		t.Errorf("different text distance = %d, want > 10", d)
+	}
+	if d := HammingDistance(0, ^uint64(0)); d != 64 {
+		t.Errorf("HammingDistance = %d, want 64", d)
+	}
+}
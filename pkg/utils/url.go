@@ -56,3 +56,42 @@ func RemoveUTMParams(url string) (string, error) {
 	tmp.RawQuery = queryEncodeWithoutEmptyValues(queries)
 	return tmp.String(), nil
 }
+
+// NormalizeURL returns the canonical form of URL which is used to detect
+// duplicated bookmarks. HTTP is treated as HTTPS, host is lower-cased,
+// `www.` prefix, default port, user info, fragment, UTM queries and
+// trailing slash are removed, and the remaining queries are sorted.
+func NormalizeURL(url string) (string, error) {
+	clean, err := RemoveUTMParams(url)
+	if err != nil {
+		return url, err
+	}
+
+	tmp, err := nurl.Parse(clean)
+	if err != nil {
+		return url, err
+	}
+
+	host := GetDomain(clean)
+	if port := tmp.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	if tmp.Scheme == "http" {
+		tmp.Scheme = "https"
+	}
+	tmp.User = nil
+	tmp.Host = host
+	tmp.Path = strings.TrimRight(tmp.Path, "/")
+	tmp.RawPath = ""
+	return tmp.String(), nil
+}
+
+// GetDomain returns the lower-cased host name of URL without `www.` prefix.
+func GetDomain(url string) string {
+	tmp, err := nurl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(tmp.Hostname()), "www.")
+}
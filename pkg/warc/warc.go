@@ -0,0 +1,250 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Version WARC 版本
+const Version = "WARC/1.1"
+
+// 记录类型
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeResource = "resource"
+	TypeMetadata = "metadata"
+)
+
+// ContentType warc 文件的 Content-Type
+const ContentType = "application/warc"
+
+// Record 一条 WARC 记录
+type Record struct {
+	Type   string
+	Header map[string]string // WARC-Type、WARC-Record-ID、WARC-Date、Content-Length 以外的头
+	Block  []byte
+}
+
+// Writer 按记录写入 WARC, 每条记录单独 gzip 压缩, 文件可以直接拼接
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter 创建 Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteRecord 写入一条记录, 返回记录 id
+func (wr *Writer) WriteRecord(record Record) (string, error) {
+	id := record.Header["WARC-Record-ID"]
+	if id == "" {
+		id = NewRecordID()
+	}
+	date := record.Header["WARC-Date"]
+	if date == "" {
+		date = FormatDate(time.Now())
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(Version + "\r\n")
+	writeField(&buf, "WARC-Type", record.Type)
+	writeField(&buf, "WARC-Record-ID", id)
+	writeField(&buf, "WARC-Date", date)
+
+	keys := make([]string, 0, len(record.Header))
+	for k := range record.Header {
+		if k != "WARC-Record-ID" && k != "WARC-Date" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeField(&buf, k, record.Header[k])
+	}
+
+	writeField(&buf, "Content-Length", strconv.Itoa(len(record.Block)))
+	buf.WriteString("\r\n")
+	buf.Write(record.Block)
+	buf.WriteString("\r\n\r\n")
+
+	gz := gzip.NewWriter(wr.w)
+	if _, err := gz.Write(buf.Bytes()); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return id, nil
+}
+
+// WriteInfo 写入 warcinfo 记录
+func (wr *Writer) WriteInfo(filename string, fields map[string]string) (string, error) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var block bytes.Buffer
+	writeField(&block, "format", "WARC File Format 1.1")
+	for _, k := range keys {
+		writeField(&block, k, fields[k])
+	}
+
+	return wr.WriteRecord(Record{
+		Type: TypeWarcinfo,
+		Header: map[string]string{
+			"WARC-Filename": filename,
+			"Content-Type":  "application/warc-fields",
+		},
+		Block: block.Bytes(),
+	})
+}
+
+// WriteExchange 写入一次 http 请求和响应, body 为已经解压的响应体
+func (wr *Writer) WriteExchange(req *http.Request, resp *http.Response, body []byte, date time.Time) error {
+	uri := req.URL.String()
+	warcDate := FormatDate(date)
+
+	responseBlock := ResponseBlock(resp, body)
+	responseID, err := wr.WriteRecord(Record{
+		Type: TypeResponse,
+		Header: map[string]string{
+			"WARC-Date":           warcDate,
+			"WARC-Target-URI":     uri,
+			"WARC-Payload-Digest": Digest(body),
+			"WARC-Block-Digest":   Digest(responseBlock),
+			"Content-Type":        "application/http;msgtype=response",
+		},
+		Block: responseBlock,
+	})
+	if err != nil {
+		return err
+	}
+
+	requestBlock := RequestBlock(req)
+	_, err = wr.WriteRecord(Record{
+		Type: TypeRequest,
+		Header: map[string]string{
+			"WARC-Date":          warcDate,
+			"WARC-Target-URI":    uri,
+			"WARC-Concurrent-To": responseID,
+			"WARC-Block-Digest":  Digest(requestBlock),
+			"Content-Type":       "application/http;msgtype=request",
+		},
+		Block: requestBlock,
+	})
+	return err
+}
+
+// WriteResource 写入没有 http 头的资源记录, 例如没有抓取记录的书签存档
+func (wr *Writer) WriteResource(uri, contentType string, body []byte, date time.Time) error {
+	_, err := wr.WriteRecord(Record{
+		Type: TypeResource,
+		Header: map[string]string{
+			"WARC-Date":         FormatDate(date),
+			"WARC-Target-URI":   uri,
+			"WARC-Block-Digest": Digest(body),
+			"Content-Type":      contentType,
+		},
+		Block: body,
+	})
+	return err
+}
+
+// WriteMetadata 写入描述 uri 的元数据记录, 例如笔记和高亮
+func (wr *Writer) WriteMetadata(uri, contentType string, body []byte, date time.Time) error {
+	_, err := wr.WriteRecord(Record{
+		Type: TypeMetadata,
+		Header: map[string]string{
+			"WARC-Date":         FormatDate(date),
+			"WARC-Target-URI":   uri,
+			"WARC-Block-Digest": Digest(body),
+			"Content-Type":      contentType,
+		},
+		Block: body,
+	})
+	return err
+}
+
+// RequestBlock 请求记录内容: 请求行和请求头
+func RequestBlock(req *http.Request) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	header.Set("Host", host)
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// ResponseBlock 响应记录内容: 状态行、响应头和响应体.
+// 响应体已经解压和去掉分块编码, 相应地调整响应头, 保证回放时内容一致.
+func ResponseBlock(resp *http.Response, body []byte) []byte {
+	var buf bytes.Buffer
+	status := resp.Status
+	if status == "" {
+		status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	}
+	fmt.Fprintf(&buf, "HTTP/1.1 %s\r\n", status)
+
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if resp.Uncompressed {
+		header.Del("Content-Encoding")
+	}
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// Digest sha1 摘要, base32 编码
+func Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// FormatDate WARC-Date 格式
+func FormatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// NewRecordID 生成 urn:uuid 格式的记录 id
+func NewRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func writeField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name + ": " + value + "\r\n")
+}